@@ -0,0 +1,152 @@
+package cryptobrokerclientgo
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc/status"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+)
+
+// defaultAsyncWorkers is the number of operations submitted with the asynchronous API running at once,
+// unless set with WithAsyncWorkers.
+const defaultAsyncWorkers = 8
+
+// Future is the result of an operation running in the background, see HashDataAsync and SignCertificateAsync.
+type Future[T any] struct {
+	done   chan struct{}
+	once   sync.Once
+	result T
+	err    error
+}
+
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+// Done returns channel closed once the operation finished.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for the operation to finish and returns its result. If ctx is done first, the context error
+// is returned, while the operation goes on. It is cancelled only with the context it was submitted with.
+func (f *Future[T]) Wait(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.result, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// complete sets result of the operation, only the first call has effect.
+func (f *Future[T]) complete(result T, err error) {
+	f.once.Do(func() {
+		f.result, f.err = result, err
+		close(f.done)
+	})
+}
+
+// asyncPool runs submitted tasks in order of submission with up to workers of them at once.
+// Workers are started on demand and stop once there are no tasks left, so an idle pool holds no goroutines.
+type asyncPool struct {
+	workers int
+
+	mu      sync.Mutex
+	running int
+	queue   []func()
+}
+
+func (p *asyncPool) submit(task func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.queue = append(p.queue, task)
+
+	workers := p.workers
+	if workers <= 0 {
+		workers = defaultAsyncWorkers
+	}
+
+	if p.running < workers {
+		p.running++
+		go p.work()
+	}
+}
+
+func (p *asyncPool) work() {
+	for {
+		p.mu.Lock()
+		if len(p.queue) == 0 {
+			p.running--
+			p.mu.Unlock()
+
+			return
+		}
+
+		task := p.queue[0]
+		p.queue[0] = nil
+		p.queue = p.queue[1:]
+		p.mu.Unlock()
+
+		task()
+	}
+}
+
+// runAsync submits call with payload to the worker pool of lib and returns its future.
+// The call counts as in flight from submission, so Shutdown waits for queued calls as well.
+// Calls cancelled while queued finish right away without being sent, calls still queued once the connection
+// is closed finish with ErrClosed.
+func runAsync[P, R any](ctx context.Context, lib *Library, call func(context.Context, P) (R, error), payload P) *Future[R] {
+	future := newFuture[R]()
+
+	done, err := lib.begin()
+	if err != nil {
+		var zero R
+		future.complete(zero, err)
+
+		return future
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		var zero R
+		future.complete(zero, status.FromContextError(ctx.Err()).Err())
+		done()
+	})
+
+	lib.async.submit(func() {
+		// the call was cancelled while queued
+		if !stop() {
+			return
+		}
+		defer done()
+
+		if lib.isClosed() {
+			var zero R
+			future.complete(zero, ErrClosed)
+
+			return
+		}
+
+		future.complete(call(ctx, payload))
+	})
+
+	return future
+}
+
+// HashDataAsync submits HashData to run in the background and returns its future right away.
+// Submitted operations are started in order with up to WithAsyncWorkers of them running at once.
+// Cancelling ctx cancels the operation, whether it is still queued or already running.
+// Input of payload must not be modified until the operation finished.
+func (lib *Library) HashDataAsync(ctx context.Context, payload HashDataPayload) *Future[*protobuf.HashDataResponse] {
+	return runAsync(ctx, lib, lib.hashData, payload)
+}
+
+// SignCertificateAsync submits SignCertificate to run in the background and returns its future right away,
+// see HashDataAsync. Audit failures are reported by the future like by SignCertificate.
+func (lib *Library) SignCertificateAsync(ctx context.Context, payload SignCertificatePayload) *Future[*protobuf.SignCertificateResponse] {
+	return runAsync(ctx, lib, lib.signCertificate, payload)
+}
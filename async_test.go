@@ -0,0 +1,296 @@
+package cryptobrokerclientgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+)
+
+// newAsyncLibrary returns library connected to broker with given async workers.
+func newAsyncLibrary(t *testing.T, broker *testBroker, workers int) *Library {
+	t.Helper()
+
+	lib, err := New(context.Background(), WithEndpoint(broker.socket), WithAsyncWorkers(workers))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { _ = lib.Close() })
+
+	return lib
+}
+
+func TestLibrary_HashDataAsync_Order(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	var mu sync.Mutex
+	var received []string
+	hashData := broker.hashData
+	broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+		mu.Lock()
+		received = append(received, string(req.GetInput()))
+		mu.Unlock()
+
+		return hashData(ctx, req)
+	}
+
+	lib := newAsyncLibrary(t, broker, 1)
+	ctx := context.Background()
+
+	var inputs []string
+	var futures []*Future[*protobuf.HashDataResponse]
+	for i := range 10 {
+		input := fmt.Sprintf("input-%d", i)
+		inputs = append(inputs, input)
+		futures = append(futures, lib.HashDataAsync(ctx, HashDataPayload{Profile: "Default", Input: []byte(input)}))
+	}
+
+	for i, future := range futures {
+		resp, err := future.Wait(ctx)
+		if err != nil {
+			t.Fatalf("Future.Wait() error = %v for %s", err, inputs[i])
+		}
+
+		want, _ := hashData(ctx, &protobuf.HashDataRequest{Input: []byte(inputs[i])})
+		if resp.GetHashValueHex() != want.GetHashValueHex() {
+			t.Errorf("Future.Wait() = %s, want hash of %s", resp.GetHashValueHex(), inputs[i])
+		}
+
+		select {
+		case <-future.Done():
+		default:
+			t.Errorf("Future.Done() is open after Wait() returned")
+		}
+	}
+
+	// a single worker sends operations in order of submission
+	if !slices.Equal(received, inputs) {
+		t.Errorf("broker received %v, want %v", received, inputs)
+	}
+}
+
+func TestLibrary_HashDataAsync_Workers(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	started, release := make(chan struct{}, 10), make(chan struct{})
+	hashData := broker.hashData
+	broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+		started <- struct{}{}
+		<-release
+
+		return hashData(ctx, req)
+	}
+
+	lib := newAsyncLibrary(t, broker, 2)
+	ctx := context.Background()
+
+	var futures []*Future[*protobuf.HashDataResponse]
+	for range 4 {
+		futures = append(futures, lib.HashDataAsync(ctx, HashDataPayload{Profile: "Default", Input: []byte("Hello world")}))
+	}
+
+	for range 2 {
+		<-started
+	}
+	select {
+	case <-started:
+		t.Fatal("operation started over the number of workers")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	for _, future := range futures {
+		if _, err := future.Wait(ctx); err != nil {
+			t.Errorf("Future.Wait() error = %v", err)
+		}
+	}
+}
+
+func TestLibrary_HashDataAsync_Cancellation(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	started, release := make(chan string, 10), make(chan struct{})
+	hashData := broker.hashData
+	broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+		started <- string(req.GetInput())
+
+		select {
+		case <-release:
+			return hashData(ctx, req)
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	lib := newAsyncLibrary(t, broker, 1)
+	ctx := context.Background()
+
+	runningCtx, cancelRunning := context.WithCancel(ctx)
+	running := lib.HashDataAsync(runningCtx, HashDataPayload{Profile: "Default", Input: []byte("running")})
+	<-started
+
+	queuedCtx, cancelQueued := context.WithCancel(ctx)
+	queued := lib.HashDataAsync(queuedCtx, HashDataPayload{Profile: "Default", Input: []byte("queued")})
+	next := lib.HashDataAsync(ctx, HashDataPayload{Profile: "Default", Input: []byte("next")})
+
+	// waiting gives up without cancelling the operation
+	waitCtx, cancelWait := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancelWait()
+	if _, err := running.Wait(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Future.Wait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// queued operation finishes right away without being sent
+	cancelQueued()
+	select {
+	case <-queued.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled queued operation did not finish")
+	}
+	if _, err := queued.Wait(ctx); status.Code(err) != codes.Canceled {
+		t.Errorf("Future.Wait() error = %v for cancelled queued operation, want code %v", err, codes.Canceled)
+	}
+
+	// running operation is cancelled as well
+	cancelRunning()
+	if _, err := running.Wait(ctx); status.Code(err) != codes.Canceled {
+		t.Errorf("Future.Wait() error = %v for cancelled running operation, want code %v", err, codes.Canceled)
+	}
+
+	if got := <-started; got != "next" {
+		t.Errorf("broker received %q, want the next operation", got)
+	}
+	close(release)
+	if _, err := next.Wait(ctx); err != nil {
+		t.Errorf("Future.Wait() error = %v", err)
+	}
+}
+
+func TestLibrary_SignCertificateAsync(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	broker.signCertificate = func(ctx context.Context, req *protobuf.SignCertificateRequest) (*protobuf.SignCertificateResponse, error) {
+		return &protobuf.SignCertificateResponse{
+			SignedCertificate: &protobuf.SignCertificateResponse_Pem{Pem: "certificate of " + req.GetCsr()},
+			Metadata:          req.GetMetadata(),
+		}, nil
+	}
+
+	lib := newAsyncLibrary(t, broker, 2)
+	ctx := context.Background()
+
+	future := lib.SignCertificateAsync(ctx, SignCertificatePayload{Profile: "Default", CSR: []byte("csr"), OutputFormat: OutputFormatPem})
+	resp, err := future.Wait(ctx)
+	if err != nil {
+		t.Fatalf("Future.Wait() error = %v", err)
+	}
+	if resp.GetPem() != "certificate of csr" {
+		t.Errorf("Future.Wait() = %q, want certificate of csr", resp.GetPem())
+	}
+
+	// invalid payloads fail like synchronous calls
+	if _, err := lib.SignCertificateAsync(ctx, SignCertificatePayload{OutputFormat: 7}).Wait(ctx); !errors.Is(err, ErrInvalidSignOutputFormat) {
+		t.Errorf("Future.Wait() error = %v, want %v", err, ErrInvalidSignOutputFormat)
+	}
+}
+
+func TestLibrary_Async_Closed(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	hashData := broker.hashData
+	broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+		started <- struct{}{}
+		<-release
+
+		return hashData(ctx, req)
+	}
+
+	lib := newAsyncLibrary(t, broker, 1)
+	ctx := context.Background()
+	payload := HashDataPayload{Profile: "Default", Input: []byte("Hello world")}
+
+	running := lib.HashDataAsync(ctx, payload)
+	<-started
+	queued := lib.HashDataAsync(ctx, payload)
+
+	// shutdown waits for queued operations as well
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- lib.Shutdown(ctx) }()
+
+	close(release)
+	for _, future := range []*Future[*protobuf.HashDataResponse]{running, queued} {
+		if _, err := future.Wait(ctx); err != nil {
+			t.Errorf("Future.Wait() error = %v", err)
+		}
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Library.Shutdown() error = %v", err)
+	}
+
+	if _, err := lib.HashDataAsync(ctx, payload).Wait(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("Future.Wait() error = %v after shutdown, want %v", err, ErrClosed)
+	}
+}
+
+func TestLibrary_Async_ClosedWhileQueued(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	started := make(chan string, 10)
+	broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+		started <- string(req.GetInput())
+		<-ctx.Done()
+
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+
+	lib := newAsyncLibrary(t, broker, 1)
+	ctx := context.Background()
+
+	running := lib.HashDataAsync(ctx, HashDataPayload{Profile: "Default", Input: []byte("running")})
+	<-started
+	queued := []*Future[*protobuf.HashDataResponse]{
+		lib.HashDataAsync(ctx, HashDataPayload{Profile: "Default", Input: []byte("queued-1")}),
+		lib.HashDataAsync(ctx, HashDataPayload{Profile: "Default", Input: []byte("queued-2")}),
+	}
+
+	if err := lib.Close(); err != nil {
+		t.Fatalf("Library.Close() error = %v", err)
+	}
+
+	// running operation is cancelled, queued ones are not sent on the closed connection
+	if _, err := running.Wait(ctx); status.Code(err) != codes.Canceled {
+		t.Errorf("Future.Wait() error = %v for running operation, want code %v", err, codes.Canceled)
+	}
+	for _, future := range queued {
+		if _, err := future.Wait(ctx); !errors.Is(err, ErrClosed) {
+			t.Errorf("Future.Wait() error = %v for queued operation, want %v", err, ErrClosed)
+		}
+	}
+
+	select {
+	case got := <-started:
+		t.Errorf("broker received %q after close", got)
+	default:
+	}
+}
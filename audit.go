@@ -0,0 +1,322 @@
+package cryptobrokerclientgo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+)
+
+var (
+	// ErrAuditFailed is returned together with the issued certificate when it could not be audited.
+	ErrAuditFailed = errors.New("audit of issued certificate failed")
+
+	// ErrAuditLogTampered is returned by VerifyAuditLog when the hash chain of entries is broken.
+	ErrAuditLogTampered = errors.New("audit log has been tampered with")
+)
+
+// AuditEntry records a certificate issued by the crypto broker.
+// It never contains the CA private key or the CSR.
+type AuditEntry struct {
+	// Time when the certificate was received
+	Time time.Time `json:"time"`
+
+	// Profile used to sign the certificate
+	Profile string `json:"profile"`
+
+	// MetadataId of the signing request
+	MetadataId string `json:"metadataId"`
+
+	// Caller identity set with WithAuditCaller
+	Caller string `json:"caller,omitempty"`
+
+	// SerialNumber of the certificate in hexadecimal
+	SerialNumber string `json:"serialNumber"`
+
+	Subject        string    `json:"subject"`
+	Issuer         string    `json:"issuer"`
+	DNSNames       []string  `json:"dnsNames,omitempty"`
+	EmailAddresses []string  `json:"emailAddresses,omitempty"`
+	IPAddresses    []string  `json:"ipAddresses,omitempty"`
+	URIs           []string  `json:"uris,omitempty"`
+	NotBefore      time.Time `json:"notBefore"`
+	NotAfter       time.Time `json:"notAfter"`
+
+	// PrevHash is the Hash of the previous entry, empty for the first one
+	PrevHash string `json:"prevHash"`
+
+	// Hash is SHA-256 of the entry without this field, or HMAC-SHA256 if the sink has a key set with WithAuditKey,
+	// chaining it to the previous entry
+	Hash string `json:"hash"`
+}
+
+// AuditOption configures WriterAuditSink, FileAuditSink and VerifyAuditLog.
+type AuditOption func(*auditSettings)
+
+type auditSettings struct {
+	key []byte
+}
+
+// WithAuditKey keys hashes of entries with HMAC-SHA256. Without a key anyone able to edit the log
+// can recompute all hashes, so the chain only detects accidental changes, unless the Hash of the last
+// entry is kept elsewhere. The key must be kept apart from the log and passed to VerifyAuditLog as well.
+func WithAuditKey(key []byte) AuditOption {
+	return func(s *auditSettings) {
+		s.key = key
+	}
+}
+
+func newAuditSettings(opts []AuditOption) auditSettings {
+	var s auditSettings
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	return s
+}
+
+// AuditSink receives entries of issued certificates, see WithAuditSink.
+// Implementations must be safe for concurrent use.
+type AuditSink interface {
+	Audit(ctx context.Context, entry AuditEntry) error
+}
+
+type auditCallerKey struct{}
+
+// WithAuditCaller returns copy of ctx whose signing calls are audited with caller identity.
+func WithAuditCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, auditCallerKey{}, caller)
+}
+
+// audit records certificate issued in resp with the configured sink, if any.
+func (lib *Library) audit(ctx context.Context, req *protobuf.SignCertificateRequest, resp *protobuf.SignCertificateResponse) error {
+	if lib.auditSink == nil {
+		return nil
+	}
+
+	entry, err := newAuditEntry(ctx, req, resp)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrAuditFailed, err)
+	}
+
+	if err := lib.auditSink.Audit(ctx, entry); err != nil {
+		return fmt.Errorf("%w: %w", ErrAuditFailed, err)
+	}
+
+	return nil
+}
+
+// newAuditEntry describes certificate issued in resp.
+func newAuditEntry(ctx context.Context, req *protobuf.SignCertificateRequest, resp *protobuf.SignCertificateResponse) (AuditEntry, error) {
+	der := resp.GetDer()
+	if pemCert := resp.GetPem(); pemCert != "" {
+		block, _ := pem.Decode([]byte(pemCert))
+		if block == nil {
+			return AuditEntry{}, errors.New("decode PEM certificate")
+		}
+
+		der = block.Bytes
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return AuditEntry{}, fmt.Errorf("parse certificate: %w", err)
+	}
+
+	entry := AuditEntry{
+		Time:           time.Now().UTC(),
+		Profile:        req.GetProfile(),
+		MetadataId:     req.GetMetadata().GetId(),
+		SerialNumber:   cert.SerialNumber.Text(16),
+		Subject:        cert.Subject.String(),
+		Issuer:         cert.Issuer.String(),
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		NotBefore:      cert.NotBefore.UTC(),
+		NotAfter:       cert.NotAfter.UTC(),
+	}
+	entry.Caller, _ = ctx.Value(auditCallerKey{}).(string)
+
+	for _, ip := range cert.IPAddresses {
+		entry.IPAddresses = append(entry.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		entry.URIs = append(entry.URIs, uri.String())
+	}
+
+	return entry, nil
+}
+
+// chain sets PrevHash and Hash of entry, keyed with key if not empty.
+func (entry *AuditEntry) chain(prevHash string, key []byte) error {
+	entry.PrevHash = prevHash
+	entry.Hash = ""
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if len(key) == 0 {
+		sum := sha256.Sum256(data)
+		entry.Hash = hex.EncodeToString(sum[:])
+
+		return nil
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	entry.Hash = hex.EncodeToString(mac.Sum(nil))
+
+	return nil
+}
+
+// WriterAuditSink writes hash-chained entries as JSON lines.
+type WriterAuditSink struct {
+	mu       sync.Mutex
+	w        io.Writer
+	key      []byte
+	prevHash string
+}
+
+// NewWriterAuditSink returns sink writing to w, starting a new hash chain.
+func NewWriterAuditSink(w io.Writer, opts ...AuditOption) *WriterAuditSink {
+	return &WriterAuditSink{w: w, key: newAuditSettings(opts).key}
+}
+
+// Audit chains entry to the previously written one and writes it as single JSON line.
+func (s *WriterAuditSink) Audit(_ context.Context, entry AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := entry.chain(s.prevHash, s.key); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	s.prevHash = entry.Hash
+
+	return nil
+}
+
+// FileAuditSink appends hash-chained entries as JSON lines to a file.
+type FileAuditSink struct {
+	*WriterAuditSink
+	file *os.File
+}
+
+// NewFileAuditSink opens file at path for appending, creating it if necessary.
+// Entries are chained to the last entry already present in the file.
+func NewFileAuditSink(path string, opts ...AuditOption) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+
+	prevHash, err := lastAuditHash(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("read audit log %s: %w", path, err)
+	}
+
+	sink := &FileAuditSink{WriterAuditSink: NewWriterAuditSink(file, opts...), file: file}
+	sink.prevHash = prevHash
+
+	return sink, nil
+}
+
+// Close closes the file.
+func (s *FileAuditSink) Close() error {
+	return s.file.Close()
+}
+
+// lastAuditHash returns Hash of the last entry in r.
+func lastAuditHash(r io.Reader) (string, error) {
+	var last []byte
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			last = append(last[:0], line...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	if last == nil {
+		return "", nil
+	}
+
+	var entry AuditEntry
+	if err := json.Unmarshal(last, &entry); err != nil {
+		return "", fmt.Errorf("decode last entry: %w", err)
+	}
+
+	return entry.Hash, nil
+}
+
+// VerifyAuditLog checks the hash chain of JSON lines written by WriterAuditSink or FileAuditSink,
+// with the same key if the sink was created with WithAuditKey. Broken chain results in error wrapping
+// ErrAuditLogTampered that names the first offending line. Without a key this only detects modified,
+// inserted, removed or reordered entries whose hashes were not recomputed, see WithAuditKey.
+// Removal of trailing entries can only be detected by comparing the Hash of the last entry
+// with one kept elsewhere.
+func VerifyAuditLog(r io.Reader, opts ...AuditOption) error {
+	key := newAuditSettings(opts).key
+	var prevHash string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry AuditEntry
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&entry); err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrAuditLogTampered, n, err)
+		}
+
+		if entry.PrevHash != prevHash {
+			return fmt.Errorf("%w: line %d: entry does not follow the previous one", ErrAuditLogTampered, n)
+		}
+
+		hash := entry.Hash
+		if err := entry.chain(prevHash, key); err != nil {
+			return err
+		}
+		if entry.Hash != hash {
+			return fmt.Errorf("%w: line %d: entry has been modified", ErrAuditLogTampered, n)
+		}
+
+		prevHash = hash
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,332 @@
+package cryptobrokerclientgo
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+)
+
+// issueTestCertificate returns PEM encoded certificate with given serial number signed by a throwaway CA.
+func issueTestCertificate(t *testing.T, serial int64) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	notBefore := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.AddDate(10, 0, 0),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "service.example.com", Organization: []string{"Example"}},
+		DNSNames:     []string{"service.example.com"},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.AddDate(1, 0, 0),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, leaf, ca, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestLibrary_SignCertificate_Audit(t *testing.T) {
+	broker := newTestBroker(t)
+	serial := int64(0x1000)
+	broker.signCertificate = func(ctx context.Context, req *protobuf.SignCertificateRequest) (*protobuf.SignCertificateResponse, error) {
+		serial++
+		return &protobuf.SignCertificateResponse{
+			SignedCertificate: &protobuf.SignCertificateResponse_Pem{Pem: issueTestCertificate(t, serial)},
+			Metadata:          req.GetMetadata(),
+		}, nil
+	}
+	broker.start(t)
+	defer broker.stop()
+
+	var log bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx, WithEndpoint(broker.socket), WithAuditSink(NewWriterAuditSink(&log)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	for _, id := range []string{"sign-1", "sign-2"} {
+		_, err := lib.SignCertificate(WithAuditCaller(ctx, "alice"), SignCertificatePayload{
+			Profile:      "Default",
+			CSR:          []byte("secret-csr"),
+			CAPrivateKey: []byte("secret-private-key"),
+			CACert:       []byte("ca-cert"),
+			OutputFormat: OutputFormatPem,
+			Metadata:     &Metadata{Id: id},
+		})
+		if err != nil {
+			t.Fatalf("Library.SignCertificate() error = %v", err)
+		}
+	}
+
+	for _, secret := range []string{"secret-csr", "secret-private-key"} {
+		if strings.Contains(log.String(), secret) {
+			t.Errorf("audit log contains %q: %s", secret, log.String())
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("audit log has %d entries, want 2", len(lines))
+	}
+
+	entries, err := readAuditLog(log.String())
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+
+	got := entries[0]
+	want := AuditEntry{
+		Profile:      "Default",
+		MetadataId:   "sign-1",
+		Caller:       "alice",
+		SerialNumber: "1001",
+		Subject:      "CN=service.example.com,O=Example",
+		Issuer:       "CN=Test CA",
+		DNSNames:     []string{"service.example.com"},
+		IPAddresses:  []string{"10.0.0.1"},
+		NotBefore:    time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		PrevHash:     "",
+		Hash:         got.Hash,
+		Time:         got.Time,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("audit entry = %+v, want %+v", got, want)
+	}
+	if got.Time.IsZero() || got.Hash == "" {
+		t.Errorf("audit entry misses time or hash: %+v", got)
+	}
+	if entries[1].PrevHash != got.Hash || entries[1].SerialNumber != "1002" {
+		t.Errorf("second audit entry = %+v, want serial 1002 chained to %s", entries[1], got.Hash)
+	}
+
+	if err := VerifyAuditLog(strings.NewReader(log.String())); err != nil {
+		t.Errorf("VerifyAuditLog() error = %v", err)
+	}
+}
+
+func TestLibrary_SignCertificate_AuditFailure(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.signCertificate = func(ctx context.Context, req *protobuf.SignCertificateRequest) (*protobuf.SignCertificateResponse, error) {
+		return &protobuf.SignCertificateResponse{SignedCertificate: &protobuf.SignCertificateResponse_Pem{Pem: issueTestCertificate(t, 1)}}, nil
+	}
+	broker.start(t)
+	defer broker.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx, WithEndpoint(broker.socket), WithAuditSink(NewWriterAuditSink(failingWriter{})))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	resp, err := lib.SignCertificate(ctx, SignCertificatePayload{Profile: "Default", OutputFormat: OutputFormatPem})
+	if !errors.Is(err, ErrAuditFailed) {
+		t.Errorf("Library.SignCertificate() error = %v, want %v", err, ErrAuditFailed)
+	}
+	if resp.GetPem() == "" {
+		t.Errorf("Library.SignCertificate() did not return the issued certificate")
+	}
+}
+
+func TestLibrary_SignCertificate_AuditReplay(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.signCertificate = func(ctx context.Context, req *protobuf.SignCertificateRequest) (*protobuf.SignCertificateResponse, error) {
+		return &protobuf.SignCertificateResponse{
+			SignedCertificate: &protobuf.SignCertificateResponse_Pem{Pem: issueTestCertificate(t, 1)},
+			Metadata:          req.GetMetadata(),
+		}, nil
+	}
+	broker.start(t)
+	defer broker.stop()
+
+	var log bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx, WithEndpoint(broker.socket), WithAuditSink(NewWriterAuditSink(&log)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	payload := SignCertificatePayload{Profile: "Default", CSR: []byte("csr"), OutputFormat: OutputFormatPem, Metadata: &Metadata{Id: "sign-1"}}
+	for range 2 {
+		if _, err := lib.SignCertificate(ctx, payload); err != nil {
+			t.Fatalf("Library.SignCertificate() error = %v", err)
+		}
+	}
+
+	// the second call is replayed by the idempotency cache, its certificate was audited when issued
+	if n := strings.Count(log.String(), "\n"); n != 1 {
+		t.Errorf("audit log has %d entries, want 1: %s", n, log.String())
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestVerifyAuditLog_Tampering(t *testing.T) {
+	var log bytes.Buffer
+	sink := NewWriterAuditSink(&log)
+	for _, id := range []string{"1", "2", "3"} {
+		if err := sink.Audit(context.Background(), AuditEntry{MetadataId: id, SerialNumber: id}); err != nil {
+			t.Fatalf("Audit() error = %v", err)
+		}
+	}
+
+	lines := strings.SplitAfter(log.String(), "\n")
+	tests := []struct {
+		name    string
+		log     string
+		wantErr bool
+	}{
+		{name: "untouched log", log: log.String()},
+		{name: "modified entry", log: strings.Replace(log.String(), `"serialNumber":"2"`, `"serialNumber":"4"`, 1), wantErr: true},
+		{name: "removed entry", log: lines[0] + lines[2], wantErr: true},
+		{name: "reordered entries", log: lines[1] + lines[0] + lines[2], wantErr: true},
+		{name: "added field", log: strings.Replace(log.String(), `{"time"`, `{"key":"x","time"`, 1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyAuditLog(strings.NewReader(tt.log))
+			if (err != nil) != tt.wantErr || (tt.wantErr && !errors.Is(err, ErrAuditLogTampered)) {
+				t.Errorf("VerifyAuditLog() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyAuditLog_Key(t *testing.T) {
+	key := []byte("audit-key")
+
+	var log bytes.Buffer
+	sink := NewWriterAuditSink(&log, WithAuditKey(key))
+	for _, id := range []string{"1", "2"} {
+		if err := sink.Audit(context.Background(), AuditEntry{MetadataId: id, SerialNumber: id}); err != nil {
+			t.Fatalf("Audit() error = %v", err)
+		}
+	}
+
+	// entry modified with the plain hashes recomputed, as anyone able to edit the log could do
+	entries, err := readAuditLog(strings.Replace(log.String(), `"serialNumber":"2"`, `"serialNumber":"4"`, 1))
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	var recomputed bytes.Buffer
+	plain := NewWriterAuditSink(&recomputed)
+	for _, entry := range entries {
+		if err := plain.Audit(context.Background(), entry); err != nil {
+			t.Fatalf("Audit() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		log     string
+		opts    []AuditOption
+		wantErr bool
+	}{
+		{name: "untouched log", log: log.String(), opts: []AuditOption{WithAuditKey(key)}},
+		{name: "recomputed hashes", log: recomputed.String(), opts: []AuditOption{WithAuditKey(key)}, wantErr: true},
+		{name: "recomputed hashes without key", log: recomputed.String()},
+		{name: "wrong key", log: log.String(), opts: []AuditOption{WithAuditKey([]byte("other-key"))}, wantErr: true},
+		{name: "without key", log: log.String(), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyAuditLog(strings.NewReader(tt.log), tt.opts...)
+			if (err != nil) != tt.wantErr || (tt.wantErr && !errors.Is(err, ErrAuditLogTampered)) {
+				t.Errorf("VerifyAuditLog() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFileAuditSink_ContinuesChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	for _, id := range []string{"1", "2"} {
+		sink, err := NewFileAuditSink(path)
+		if err != nil {
+			t.Fatalf("NewFileAuditSink() error = %v", err)
+		}
+
+		if err := sink.Audit(context.Background(), AuditEntry{MetadataId: id}); err != nil {
+			t.Fatalf("Audit() error = %v", err)
+		}
+
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+
+	if n := strings.Count(string(data), "\n"); n != 2 {
+		t.Fatalf("audit log has %d entries, want 2", n)
+	}
+	if err := VerifyAuditLog(bytes.NewReader(data)); err != nil {
+		t.Errorf("VerifyAuditLog() error = %v", err)
+	}
+}
+
+func readAuditLog(log string) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	for _, line := range strings.Split(strings.TrimSpace(log), "\n") {
+		var entry AuditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
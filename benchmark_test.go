@@ -3,8 +3,11 @@ package cryptobrokerclientgo
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
 	"github.com/stretchr/testify/mock"
@@ -109,3 +112,74 @@ func TestLibrary_BenchmarkData(t *testing.T) {
 		})
 	}
 }
+
+// Server-side limits of the broker in BenchmarkLibrary_HashData_Pool.
+const (
+	// benchmarkHashDelay is the time the broker takes for a hash, without using CPU of the benchmark
+	benchmarkHashDelay = time.Millisecond
+
+	// benchmarkMaxStreams is the number of calls the broker serves at once on one connection
+	benchmarkMaxStreams = 4
+)
+
+// BenchmarkLibrary_HashData_Pool measures throughput of concurrent hashing with growing connection pools.
+// The broker takes benchmarkHashDelay per call and serves up to benchmarkMaxStreams calls per connection,
+// like a remote server bounding its connections, so throughput grows with pool size even on a single CPU
+// until the client runs out of CPU. Run with -cpu to vary the number of concurrent callers, e.g. -cpu 1,8.
+func BenchmarkLibrary_HashData_Pool(b *testing.B) {
+	payload := HashDataPayload{Profile: "Default", Input: make([]byte, 1024)}
+
+	for _, size := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("PoolSize=%d", size), func(b *testing.B) {
+			broker := newTestBroker(b)
+			hashData := broker.hashData
+			broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+				time.Sleep(benchmarkHashDelay)
+				return hashData(ctx, req)
+			}
+
+			lis, err := net.Listen("unix", broker.socket)
+			if err != nil {
+				b.Fatalf("listen on %s: %v", broker.socket, err)
+			}
+			broker.serve(lis, grpc.MaxConcurrentStreams(benchmarkMaxStreams))
+			defer broker.stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			lib, err := New(ctx, WithEndpoint(broker.socket), WithPoolSize(size))
+			if err != nil {
+				b.Fatalf("New() error = %v", err)
+			}
+			defer lib.Close()
+
+			// measure only once all connections of the pool are ready
+			for broker.accepted.Load() < int64(size) {
+				if ctx.Err() != nil {
+					b.Fatalf("broker accepted %d connections, want %d", broker.accepted.Load(), size)
+				}
+				time.Sleep(time.Millisecond)
+			}
+			if err := lib.WaitForReady(ctx); err != nil {
+				b.Fatalf("Library.WaitForReady() error = %v", err)
+			}
+
+			// enough callers to fill the streams of the largest pool
+			b.SetParallelism(32)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := lib.HashData(context.Background(), payload); err != nil {
+						b.Errorf("Library.HashData() error = %v", err)
+						return
+					}
+				}
+			})
+
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "calls/s")
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package cryptobrokerclientgo
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// testBroker is an in-process crypto broker server listening on a Unix socket.
+// Handlers can be replaced by tests to simulate particular server behaviour.
+type testBroker struct {
+	protobuf.UnimplementedCryptoGrpcServer
+	protobuf.UnimplementedCryptoGrpcDevServer
+
+	socket string
+	server *grpc.Server
+	health *health.Server
+
+	// accepted counts client connections
+	accepted atomic.Int64
+
+	hashData        func(context.Context, *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error)
+	signCertificate func(context.Context, *protobuf.SignCertificateRequest) (*protobuf.SignCertificateResponse, error)
+}
+
+// newTestBroker returns broker with default handlers, bound to a fresh socket path.
+// Socket directory is kept short, because Unix socket paths are limited to ~100 bytes.
+func newTestBroker(t testing.TB) *testBroker {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "ocb")
+	if err != nil {
+		t.Fatalf("create socket dir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	return &testBroker{
+		socket: filepath.Join(dir, "broker.sock"),
+		hashData: func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+			digest := sha256.Sum256(req.GetInput())
+			return &protobuf.HashDataResponse{
+				HashAlgorithm: "sha256",
+				HashValue:     &protobuf.HashDataResponse_HashValueHex{HashValueHex: hex.EncodeToString(digest[:])},
+				Metadata:      req.GetMetadata(),
+			}, nil
+		},
+	}
+}
+
+// startTestBroker starts new test broker and points default socket path of the library to it.
+func startTestBroker(t testing.TB) *testBroker {
+	t.Helper()
+
+	b := newTestBroker(t)
+
+	socketPath := defaultSocketPath
+	defaultSocketPath = b.socket
+	t.Cleanup(func() { defaultSocketPath = socketPath })
+
+	b.start(t)
+	t.Cleanup(b.stop)
+
+	return b
+}
+
+// start serves the broker on its socket, it can be called again after stop.
+func (b *testBroker) start(t testing.TB) {
+	t.Helper()
+
+	_ = os.Remove(b.socket)
+	lis, err := net.Listen("unix", b.socket)
+	if err != nil {
+		t.Fatalf("listen on %s: %v", b.socket, err)
+	}
+
+	b.serve(lis)
+}
+
+// startTLS serves the broker with TLS on a local TCP port instead of its socket. It returns address of the broker
+// and path to PEM encoded certificate of the broker, which is self-signed for 127.0.0.1 and broker.test.
+func (b *testBroker) startTLS(t testing.TB) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "broker.test"},
+		DNSNames:              []string{"broker.test"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+
+	caFile := filepath.Join(filepath.Dir(b.socket), "broker.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write certificate: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on tcp: %v", err)
+	}
+
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	b.serve(lis, grpc.Creds(credentials.NewServerTLSFromCert(&cert)))
+
+	return lis.Addr().String(), caFile
+}
+
+// serve serves the broker on lis.
+func (b *testBroker) serve(lis net.Listener, opts ...grpc.ServerOption) {
+	b.server = grpc.NewServer(opts...)
+	b.health = health.NewServer()
+	protobuf.RegisterCryptoGrpcServer(b.server, b)
+	protobuf.RegisterCryptoGrpcDevServer(b.server, b)
+	grpc_health_v1.RegisterHealthServer(b.server, b.health)
+
+	go func() { _ = b.server.Serve(&countingListener{Listener: lis, accepted: &b.accepted}) }()
+}
+
+// countingListener counts accepted connections.
+type countingListener struct {
+	net.Listener
+	accepted *atomic.Int64
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted.Add(1)
+	}
+
+	return conn, err
+}
+
+// stop closes all connections and the listener immediately.
+func (b *testBroker) stop() {
+	if b.server != nil {
+		b.server.Stop()
+	}
+}
+
+func (b *testBroker) HashData(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+	return b.hashData(ctx, req)
+}
+
+func (b *testBroker) SignCertificate(ctx context.Context, req *protobuf.SignCertificateRequest) (*protobuf.SignCertificateResponse, error) {
+	if b.signCertificate == nil {
+		return b.UnimplementedCryptoGrpcServer.SignCertificate(ctx, req)
+	}
+
+	return b.signCertificate(ctx, req)
+}
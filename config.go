@@ -0,0 +1,409 @@
+package cryptobrokerclientgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"google.golang.org/grpc/codes"
+	"gopkg.in/yaml.v3"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/balancer"
+)
+
+// EnvPrefix is the prefix of environment variables overriding settings loaded by LoadConfig.
+// Variable names are derived from the path of the setting in the configuration document,
+// e.g. retry.maxAttempts is overridden by CRYPTO_BROKER_RETRY_MAX_ATTEMPTS.
+// Lists are given as comma separated values, e.g. CRYPTO_BROKER_RETRY_RETRYABLE_STATUS_CODES=14,8.
+const EnvPrefix = "CRYPTO_BROKER_"
+
+// Config gathers all settings of the library.
+// It can be loaded from a file with LoadConfig and passed to NewLibrary.
+type Config struct {
+	// Endpoint path to Unix domain socket of the crypto broker server
+	Endpoint string `yaml:"endpoint"`
+
+	// LoadBalancing settings of multiple crypto broker servers, Endpoint is ignored when any endpoints are set
+	LoadBalancing LoadBalancingConfig `yaml:"loadBalancing"`
+
+	// Grpc connectivity settings
+	Grpc GrpcConfig `yaml:"grpc"`
+
+	// Retry settings of the retry interceptor
+	Retry RetryConfig `yaml:"retry"`
+
+	// CircuitBreaker settings of the circuit breaker interceptor
+	CircuitBreaker CircuitConfig `yaml:"circuitBreaker"`
+
+	// Idempotency settings of signing replays
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+
+	// Concurrency limits of calls in flight, unlimited by default
+	Concurrency ConcurrencyConfig `yaml:"concurrency"`
+
+	// RateLimit of calls per second, unlimited by default
+	RateLimit RateLimitConfig `yaml:"rateLimit"`
+
+	// Cache of results, disabled by default
+	Cache CacheConfig `yaml:"cache"`
+
+	// Coalescing of identical concurrent calls, disabled by default
+	Coalescing CoalescingConfig `yaml:"coalescing"`
+
+	// Hedging of slow calls, disabled by default
+	Hedging HedgingConfig `yaml:"hedging"`
+
+	// Metadata settings of request metadata propagation and verification
+	Metadata MetadataConfig `yaml:"metadata"`
+}
+
+// DefaultConfig returns configuration used by NewLibrary when no custom configuration is provided.
+func DefaultConfig() Config {
+	// Signing may allocate serial numbers on the server, so it is only retried with an idempotency key.
+	notIdempotent := false
+
+	return Config{
+		Endpoint: defaultSocketPath,
+		Grpc:     GrpcConfig{ConnMaxRetries: 60, ReconnectMaxBackoff: "5s"},
+		Retry: RetryConfig{
+			MaxAttempts:          5,
+			InitialBackoff:       "500ms",
+			BackoffMultiplier:    2.0,
+			RetryableStatusCodes: []codes.Code{14, 8, 10},
+			MaxBackoff:           "5s",
+			Jitter:               JitterEqual,
+			Budget:               &RetryBudgetConfig{MaxTokens: 10, TokenRatio: 0.1},
+			Methods: map[string]RetryConfig{
+				MethodSignCertificate: {Idempotent: &notIdempotent},
+			},
+		},
+		CircuitBreaker: CircuitConfig{
+			Name:                "crypto-grpc",
+			MaxRequests:         3,
+			Interval:            "30s",
+			Timeout:             "5s",
+			ConsecutiveFailures: 3,
+			FailureStatusCodes:  []codes.Code{14, 8, 10},
+		},
+		// Replayed signing requests get the first issued certificate instead of a new one.
+		Idempotency: IdempotencyConfig{
+			Window:  "5m",
+			Methods: []string{MethodSignCertificate},
+		},
+	}
+}
+
+// LoadConfig reads configuration from YAML or JSON document at path.
+// Settings missing in the document keep their default values, see DefaultConfig.
+// Afterwards environment variables prefixed with EnvPrefix are applied and the result is validated.
+// Returned error names the offending keys.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	config := DefaultConfig()
+
+	// JSON is a subset of YAML, so a single decoder handles both formats
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("decode config %s: %w", path, err)
+	}
+
+	if err := applyEnv(reflect.ValueOf(&config).Elem(), EnvPrefix, ""); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	return &config, nil
+}
+
+// Validate checks all settings and returns errors naming the offending keys.
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Endpoint == "" {
+		errs = append(errs, configError("endpoint", "must not be empty"))
+	}
+
+	for i, endpoint := range c.LoadBalancing.Endpoints {
+		if endpoint.Endpoint == "" {
+			errs = append(errs, configError(fmt.Sprintf("loadBalancing.endpoints[%d].endpoint", i), "must not be empty"))
+		}
+		if endpoint.Network != "" && !slices.Contains(balancer.Networks, endpoint.Network) {
+			errs = append(errs, configError(fmt.Sprintf("loadBalancing.endpoints[%d].network", i), "must be one of %q, got %q", balancer.Networks, endpoint.Network))
+		}
+		if endpoint.TLS != nil && endpoint.Network != balancer.NetworkTCP {
+			errs = append(errs, configError(fmt.Sprintf("loadBalancing.endpoints[%d].tls", i), "is only supported with network %q", balancer.NetworkTCP))
+		}
+	}
+	if policy := c.LoadBalancing.Policy; policy != "" && !slices.Contains(balancer.Policies, policy) {
+		errs = append(errs, configError("loadBalancing.policy", "must be one of %q, got %q", balancer.Policies, policy))
+	}
+
+	switch {
+	case c.Grpc.ConnectTimeout < 0:
+		errs = append(errs, configError("grpc.connectTimeout", "must not be negative, got %v", c.Grpc.ConnectTimeout))
+	case c.Grpc.ConnMaxRetries < 0:
+		errs = append(errs, configError("grpc.connMaxRetries", "must not be negative, got %d", c.Grpc.ConnMaxRetries))
+	case c.Grpc.ConnectTimeout == 0 && c.Grpc.ConnMaxRetries == 0 && !c.Grpc.Lazy:
+		// only New of eager mode waits for the connection
+		errs = append(errs, configError("grpc.connectTimeout", "must be positive unless grpc.lazy is set"))
+	}
+	if c.Grpc.PoolSize < 0 {
+		errs = append(errs, configError("grpc.poolSize", "must not be negative, got %d", c.Grpc.PoolSize))
+	}
+	errs = append(errs, validateDuration("grpc.reconnectMaxBackoff", c.Grpc.ReconnectMaxBackoff, false)...)
+
+	errs = append(errs, validateRetry("retry", c.Retry, true)...)
+	for _, method := range slices.Sorted(maps.Keys(c.Retry.Methods)) {
+		errs = append(errs, validateRetry(fmt.Sprintf("retry.methods[%s]", method), c.Retry.Methods[method], false)...)
+	}
+	for _, profile := range slices.Sorted(maps.Keys(c.Retry.Profiles)) {
+		errs = append(errs, validateRetry(fmt.Sprintf("retry.profiles[%s]", profile), c.Retry.Profiles[profile], false)...)
+	}
+
+	errs = append(errs, validateCircuit("circuitBreaker", c.CircuitBreaker, true)...)
+	for _, method := range slices.Sorted(maps.Keys(c.CircuitBreaker.Methods)) {
+		errs = append(errs, validateCircuit(fmt.Sprintf("circuitBreaker.methods[%s]", method), c.CircuitBreaker.Methods[method], false)...)
+	}
+	for _, profile := range slices.Sorted(maps.Keys(c.CircuitBreaker.Profiles)) {
+		errs = append(errs, validateCircuit(fmt.Sprintf("circuitBreaker.profiles[%s]", profile), c.CircuitBreaker.Profiles[profile], false)...)
+	}
+
+	errs = append(errs, validateDuration("idempotency.window", c.Idempotency.Window, true)...)
+	if c.Idempotency.MaxEntries < 0 {
+		errs = append(errs, configError("idempotency.maxEntries", "must not be negative, got %d", c.Idempotency.MaxEntries))
+	}
+
+	errs = append(errs, validateConcurrency("concurrency", c.Concurrency)...)
+	for _, method := range slices.Sorted(maps.Keys(c.Concurrency.Methods)) {
+		errs = append(errs, validateConcurrency(fmt.Sprintf("concurrency.methods[%s]", method), c.Concurrency.Methods[method])...)
+	}
+	for _, profile := range slices.Sorted(maps.Keys(c.Concurrency.Profiles)) {
+		errs = append(errs, validateConcurrency(fmt.Sprintf("concurrency.profiles[%s]", profile), c.Concurrency.Profiles[profile])...)
+	}
+
+	errs = append(errs, validateRateLimit("rateLimit", c.RateLimit)...)
+	for _, method := range slices.Sorted(maps.Keys(c.RateLimit.Methods)) {
+		errs = append(errs, validateRateLimit(fmt.Sprintf("rateLimit.methods[%s]", method), c.RateLimit.Methods[method])...)
+	}
+	for _, profile := range slices.Sorted(maps.Keys(c.RateLimit.Profiles)) {
+		errs = append(errs, validateRateLimit(fmt.Sprintf("rateLimit.profiles[%s]", profile), c.RateLimit.Profiles[profile])...)
+	}
+
+	if c.Cache.MaxBytes < 0 {
+		errs = append(errs, configError("cache.maxBytes", "must not be negative, got %d", c.Cache.MaxBytes))
+	}
+	// results expire, so that changes of profiles on the server are noticed
+	errs = append(errs, validateDuration("cache.ttl", c.Cache.TTL, c.Cache.MaxBytes > 0)...)
+	if ttl, err := time.ParseDuration(c.Cache.TTL); err == nil && ttl == 0 && c.Cache.MaxBytes > 0 {
+		errs = append(errs, configError("cache.ttl", "must be positive when cache.maxBytes is set, got %q", c.Cache.TTL))
+	}
+
+	if len(c.Hedging.Methods) > 0 {
+		errs = append(errs, validateDuration("hedging.delay", c.Hedging.Delay, true)...)
+	}
+	if c.Hedging.Percentile < 0 || c.Hedging.Percentile >= 100 {
+		errs = append(errs, configError("hedging.percentile", "must be between 0 and 100, got %v", c.Hedging.Percentile))
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateRetry(key string, c RetryConfig, required bool) []error {
+	var errs []error
+
+	errs = append(errs, validateDuration(key+".initialBackoff", c.InitialBackoff, required)...)
+	errs = append(errs, validateDuration(key+".maxBackoff", c.MaxBackoff, false)...)
+	errs = append(errs, validateDuration(key+".totalTimeout", c.TotalTimeout, false)...)
+	errs = append(errs, validateCodes(key+".retryableStatusCodes", c.RetryableStatusCodes)...)
+
+	if c.BackoffMultiplier < 0 {
+		errs = append(errs, configError(key+".backoffMultiplier", "must not be negative, got %v", c.BackoffMultiplier))
+	}
+
+	switch c.Jitter {
+	case "", JitterNone, JitterFull, JitterEqual:
+	default:
+		errs = append(errs, configError(key+".jitter", "must be one of %q, %q or %q, got %q",
+			JitterNone, JitterFull, JitterEqual, c.Jitter))
+	}
+
+	if c.Budget != nil {
+		if c.Budget.MaxTokens <= 0 {
+			errs = append(errs, configError(key+".budget.maxTokens", "must be positive, got %v", c.Budget.MaxTokens))
+		}
+		if c.Budget.TokenRatio <= 0 {
+			errs = append(errs, configError(key+".budget.tokenRatio", "must be positive, got %v", c.Budget.TokenRatio))
+		}
+	}
+
+	return errs
+}
+
+func validateCircuit(key string, c CircuitConfig, required bool) []error {
+	var errs []error
+
+	errs = append(errs, validateDuration(key+".interval", c.Interval, required)...)
+	errs = append(errs, validateDuration(key+".timeout", c.Timeout, required)...)
+	errs = append(errs, validateCodes(key+".failureStatusCodes", c.FailureStatusCodes)...)
+
+	if required && c.ConsecutiveFailures == 0 {
+		errs = append(errs, configError(key+".consecutiveFailures", "must be positive"))
+	}
+
+	return errs
+}
+
+func validateConcurrency(key string, c ConcurrencyConfig) []error {
+	errs := validateDuration(key+".queueTimeout", c.QueueTimeout, false)
+
+	switch c.Adaptive {
+	case "", AdaptiveAIMD, AdaptiveGradient:
+	default:
+		errs = append(errs, configError(key+".adaptive", "must be one of %q or %q, got %q", AdaptiveAIMD, AdaptiveGradient, c.Adaptive))
+	}
+
+	if c.MaxConcurrent != 0 && c.MinConcurrent > c.MaxConcurrent {
+		errs = append(errs, configError(key+".minConcurrent", "must not exceed maxConcurrent %d, got %d", c.MaxConcurrent, c.MinConcurrent))
+	}
+
+	return errs
+}
+
+func validateRateLimit(key string, c RateLimitConfig) []error {
+	errs := validateDuration(key+".maxWait", c.MaxWait, false)
+
+	if c.Rate < 0 {
+		errs = append(errs, configError(key+".rate", "must not be negative, got %v", c.Rate))
+	}
+
+	switch c.Mode {
+	case "", RateLimitWait, RateLimitFailFast:
+	default:
+		errs = append(errs, configError(key+".mode", "must be one of %q or %q, got %q", RateLimitWait, RateLimitFailFast, c.Mode))
+	}
+
+	return errs
+}
+
+func validateDuration(key, value string, required bool) []error {
+	if value == "" {
+		if required {
+			return []error{configError(key, "must not be empty")}
+		}
+
+		return nil
+	}
+
+	if d, err := time.ParseDuration(value); err != nil || d < 0 {
+		return []error{configError(key, "must be a non-negative duration such as \"500ms\", got %q", value)}
+	}
+
+	return nil
+}
+
+func validateCodes(key string, values []codes.Code) []error {
+	var errs []error
+	for i, c := range values {
+		if c > codes.Unauthenticated {
+			errs = append(errs, configError(fmt.Sprintf("%s[%d]", key, i), "unknown gRPC status code %d", c))
+		}
+	}
+
+	return errs
+}
+
+func configError(key, format string, args ...any) error {
+	return fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...))
+}
+
+// applyEnv overrides fields of struct v with environment variables derived from their YAML keys.
+// Maps are not supported, per-method and per-profile overrides can only be set in the document.
+func applyEnv(v reflect.Value, env, key string) error {
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		fieldEnv := env + envName(name)
+		fieldKey := name
+		if key != "" {
+			fieldKey = key + "." + name
+		}
+
+		fv := v.Field(i)
+		switch {
+		case fv.Kind() == reflect.Struct:
+			if err := applyEnv(fv, fieldEnv+"_", fieldKey); err != nil {
+				return err
+			}
+		case fv.Kind() == reflect.Pointer && fv.Type().Elem().Kind() == reflect.Struct:
+			// allocate only when any nested variable is set
+			nested := reflect.New(fv.Type().Elem())
+			if !fv.IsNil() {
+				nested.Elem().Set(fv.Elem())
+			}
+
+			if err := applyEnv(nested.Elem(), fieldEnv+"_", fieldKey); err != nil {
+				return err
+			}
+
+			if !fv.IsNil() || !nested.Elem().IsZero() {
+				fv.Set(nested)
+			}
+		case fv.Kind() == reflect.Map:
+		default:
+			value, ok := os.LookupEnv(fieldEnv)
+			if !ok {
+				continue
+			}
+
+			switch fv.Kind() {
+			case reflect.String:
+				fv.SetString(value)
+				continue
+			case reflect.Slice:
+				value = "[" + value + "]"
+			default:
+			}
+
+			if err := yaml.Unmarshal([]byte(value), fv.Addr().Interface()); err != nil {
+				return configError(fieldKey, "invalid value %q of %s: %v", value, fieldEnv, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// envName converts camelCase YAML key into SCREAMING_SNAKE_CASE.
+func envName(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteByte('_')
+		}
+
+		b.WriteRune(unicode.ToUpper(r))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,247 @@
+package cryptobrokerclientgo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		document string
+		env      map[string]string
+		check    func(t *testing.T, c *Config)
+		wantErr  []string
+	}{
+		{
+			name: "LoadConfig() reads YAML document and keeps defaults of missing settings",
+			file: "config.yaml",
+			document: `
+endpoint: /var/run/broker.sock
+grpc:
+  connMaxRetries: 5
+retry:
+  maxAttempts: 2
+  retryableStatusCodes:
+    - 14 # UNAVAILABLE
+  methods:
+    /CryptoBroker.CryptoGrpc/HashData:
+      maxAttempts: 7
+circuitBreaker:
+  timeout: 10s
+`,
+			check: func(t *testing.T, c *Config) {
+				if c.Endpoint != "/var/run/broker.sock" || c.Grpc.ConnMaxRetries != 5 {
+					t.Errorf("LoadConfig() endpoint = %q, connMaxRetries = %d", c.Endpoint, c.Grpc.ConnMaxRetries)
+				}
+				if c.Retry.MaxAttempts != 2 || !reflect.DeepEqual(c.Retry.RetryableStatusCodes, []codes.Code{codes.Unavailable}) {
+					t.Errorf("LoadConfig() retry = %+v", c.Retry)
+				}
+				if c.Retry.Methods[MethodHashData].MaxAttempts != 7 {
+					t.Errorf("LoadConfig() retry method override = %+v", c.Retry.Methods)
+				}
+				if c.Retry.InitialBackoff != "500ms" || c.CircuitBreaker.Name != "crypto-grpc" {
+					t.Errorf("LoadConfig() did not keep defaults, retry = %+v, breaker = %+v", c.Retry, c.CircuitBreaker)
+				}
+				if c.CircuitBreaker.Timeout != "10s" {
+					t.Errorf("LoadConfig() breaker timeout = %q", c.CircuitBreaker.Timeout)
+				}
+			},
+		},
+		{
+			name:     "LoadConfig() reads JSON document",
+			file:     "config.json",
+			document: "{\n\t\"endpoint\": \"/var/run/broker.sock\",\n\t\"retry\": {\"maxAttempts\": 3, \"jitter\": \"full\"}\n}",
+			check: func(t *testing.T, c *Config) {
+				if c.Endpoint != "/var/run/broker.sock" || c.Retry.MaxAttempts != 3 || c.Retry.Jitter != "full" {
+					t.Errorf("LoadConfig() = %+v", c)
+				}
+			},
+		},
+		{
+			name:     "LoadConfig() applies environment overrides",
+			file:     "config.yaml",
+			document: "endpoint: /var/run/broker.sock\n",
+			env: map[string]string{
+				"CRYPTO_BROKER_ENDPOINT":                             "/tmp/override.sock",
+				"CRYPTO_BROKER_GRPC_CONNECT_TIMEOUT":                 "15s",
+				"CRYPTO_BROKER_RETRY_MAX_ATTEMPTS":                   "9",
+				"CRYPTO_BROKER_RETRY_RETRYABLE_STATUS_CODES":         "14,8",
+				"CRYPTO_BROKER_RETRY_BUDGET_TOKEN_RATIO":             "0.5",
+				"CRYPTO_BROKER_CIRCUIT_BREAKER_CONSECUTIVE_FAILURES": "4",
+			},
+			check: func(t *testing.T, c *Config) {
+				if c.Endpoint != "/tmp/override.sock" || c.Retry.MaxAttempts != 9 || c.CircuitBreaker.ConsecutiveFailures != 4 {
+					t.Errorf("LoadConfig() = %+v", c)
+				}
+				if c.Grpc.ConnectTimeout != 15*time.Second {
+					t.Errorf("LoadConfig() connect timeout = %v", c.Grpc.ConnectTimeout)
+				}
+				if !reflect.DeepEqual(c.Retry.RetryableStatusCodes, []codes.Code{codes.Unavailable, codes.ResourceExhausted}) {
+					t.Errorf("LoadConfig() retryable codes = %v", c.Retry.RetryableStatusCodes)
+				}
+				if c.Retry.Budget == nil || c.Retry.Budget.TokenRatio != 0.5 || c.Retry.Budget.MaxTokens != 10 {
+					t.Errorf("LoadConfig() budget = %+v", c.Retry.Budget)
+				}
+			},
+		},
+		{
+			name: "LoadConfig() fails naming all invalid keys",
+			file: "config.yaml",
+			document: `
+grpc:
+  connectTimeout: -1s
+loadBalancing:
+  policy: random
+  endpoints:
+    - weight: 2
+    - endpoint: /tmp/broker.sock
+      network: udp
+    - endpoint: /tmp/broker.sock
+      tls: {}
+rateLimit:
+  rate: -1
+  mode: drop
+hedging:
+  methods: [/CryptoBroker.CryptoGrpc/HashData]
+  percentile: 100
+cache:
+  maxBytes: -1
+  ttl: soon
+idempotency:
+  maxEntries: -1
+concurrency:
+  maxConcurrent: 4
+  minConcurrent: 8
+  adaptive: vegas
+retry:
+  initialBackoff: soon
+  jitter: random
+  methods:
+    /CryptoBroker.CryptoGrpc/HashData:
+      maxBackoff: later
+circuitBreaker:
+  failureStatusCodes: [99]
+`,
+			wantErr: []string{
+				"grpc.connectTimeout",
+				"loadBalancing.policy",
+				"loadBalancing.endpoints[0].endpoint",
+				"loadBalancing.endpoints[1].network",
+				"loadBalancing.endpoints[2].tls",
+				"concurrency.adaptive",
+				"concurrency.minConcurrent",
+				"rateLimit.rate",
+				"rateLimit.mode",
+				"cache.maxBytes",
+				"cache.ttl",
+				"idempotency.maxEntries",
+				"hedging.delay",
+				"hedging.percentile",
+				"retry.initialBackoff",
+				"retry.jitter",
+				"retry.methods[/CryptoBroker.CryptoGrpc/HashData].maxBackoff",
+				"circuitBreaker.failureStatusCodes[0]",
+			},
+		},
+		{
+			name:     "LoadConfig() accepts zero connect timeout in lazy mode",
+			file:     "config.yaml",
+			document: "grpc:\n  connMaxRetries: 0\n  lazy: true\n",
+			check: func(t *testing.T, c *Config) {
+				if !c.Grpc.Lazy || c.Grpc.connectTimeout() != 0 {
+					t.Errorf("LoadConfig() grpc = %+v", c.Grpc)
+				}
+			},
+		},
+		{
+			name:     "LoadConfig() fails on zero connect timeout",
+			file:     "config.yaml",
+			document: "grpc:\n  connMaxRetries: 0\n",
+			wantErr:  []string{"grpc.connectTimeout"},
+		},
+		{
+			name:     "LoadConfig() fails on zero cache TTL",
+			file:     "config.yaml",
+			document: "cache:\n  maxBytes: 1024\n  ttl: 0s\n",
+			wantErr:  []string{"cache.ttl"},
+		},
+		{
+			name:     "LoadConfig() fails on unknown keys",
+			file:     "config.yaml",
+			document: "retry:\n  maxAttempt: 3\n",
+			wantErr:  []string{"maxAttempt"},
+		},
+		{
+			name:     "LoadConfig() fails on invalid environment override",
+			file:     "config.yaml",
+			document: "",
+			env:      map[string]string{"CRYPTO_BROKER_GRPC_CONN_MAX_RETRIES": "many"},
+			wantErr:  []string{"grpc.connMaxRetries", "CRYPTO_BROKER_GRPC_CONN_MAX_RETRIES"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.document), 0o600); err != nil {
+				t.Fatalf("write config: %v", err)
+			}
+
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadConfig(path)
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			for _, key := range tt.wantErr {
+				if !strings.Contains(err.Error(), key) {
+					t.Errorf("LoadConfig() error = %q, want it to name %q", err.Error(), key)
+				}
+			}
+
+			if tt.check != nil {
+				tt.check(t, got)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_MissingFile(t *testing.T) {
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("LoadConfig() expected error for missing file")
+	}
+}
+
+func TestDefaultConfig_Valid(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatalf("DefaultConfig().Validate() error = %v", err)
+	}
+}
+
+func TestLoadConfig_CircuitBreakerSample(t *testing.T) {
+	config, err := LoadConfig("circuit_breaker.yaml")
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	want := DefaultConfig().CircuitBreaker
+	want.Name = "crypto-grpc"
+	want.MaxRequests = 3
+	want.Interval = "30s"
+	want.Timeout = "5s"
+	want.ConsecutiveFailures = 3
+	want.FailureStatusCodes = []codes.Code{codes.Unavailable, codes.ResourceExhausted, codes.Aborted}
+	if !reflect.DeepEqual(config.CircuitBreaker, want) {
+		t.Errorf("LoadConfig() circuit breaker = %+v, want %+v", config.CircuitBreaker, want)
+	}
+}
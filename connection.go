@@ -0,0 +1,82 @@
+package cryptobrokerclientgo
+
+import (
+	"context"
+
+	"google.golang.org/grpc/connectivity"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/interceptor"
+)
+
+// ConnectionState is the connectivity state of the connection to the crypto broker.
+type ConnectionState = connectivity.State
+
+// Connectivity states, see State.
+const (
+	StateIdle             = connectivity.Idle
+	StateConnecting       = connectivity.Connecting
+	StateReady            = connectivity.Ready
+	StateTransientFailure = connectivity.TransientFailure
+	StateShutdown         = connectivity.Shutdown
+)
+
+// WithWaitForReady returns copy of ctx whose calls wait until the connection is ready,
+// e.g. while the broker restarts, or fail fast with UNAVAILABLE when it is not.
+// It overrides GrpcConfig.WaitForReady for calls made with the context.
+func WithWaitForReady(ctx context.Context, waitForReady bool) context.Context {
+	return interceptor.WithWaitForReady(ctx, waitForReady)
+}
+
+// State returns the current connectivity state. The connection is re-established
+// automatically when the broker goes away and comes back.
+func (lib *Library) State() ConnectionState {
+	lib.mu.Lock()
+	closing := lib.closing
+	lib.mu.Unlock()
+
+	if closing || lib.conn == nil {
+		return StateShutdown
+	}
+
+	return lib.conn.GetState()
+}
+
+// WaitForReady triggers connecting if the connection is idle and blocks until it is ready,
+// or returns error when ctx is done first. It returns ErrClosed once the library is closed.
+func (lib *Library) WaitForReady(ctx context.Context) error {
+	done, err := lib.begin()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return lib.verifyConnection(ctx)
+}
+
+// WatchState returns channel receiving the current connectivity state followed by its changes.
+// States changing faster than they are received are skipped, the latest one is always delivered.
+// The channel is closed after StateShutdown has been sent or when ctx is done.
+func (lib *Library) WatchState(ctx context.Context) <-chan ConnectionState {
+	states := make(chan ConnectionState)
+
+	go func() {
+		defer close(states)
+
+		state := lib.State()
+		for {
+			select {
+			case states <- state:
+			case <-ctx.Done():
+				return
+			}
+
+			if state == StateShutdown || !lib.conn.WaitForStateChange(ctx, state) {
+				return
+			}
+
+			state = lib.State()
+		}
+	}()
+
+	return states
+}
@@ -0,0 +1,186 @@
+package cryptobrokerclientgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLibrary_Reconnect(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx,
+		WithEndpoint(broker.socket),
+		WithGrpcConfig(GrpcConfig{ConnMaxRetries: 10, ReconnectMaxBackoff: "100ms"}),
+		WithRetry(RetryConfig{MaxAttempts: 1, InitialBackoff: "1ms"}),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	if got := lib.State(); got != StateReady {
+		t.Fatalf("Library.State() = %v, want %v", got, StateReady)
+	}
+
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	defer stopWatching()
+	states := lib.WatchState(watchCtx)
+	if got := <-states; got != StateReady {
+		t.Fatalf("Library.WatchState() first state = %v, want %v", got, StateReady)
+	}
+
+	broker.stop()
+
+	// the change is announced as soon as the broker goes away
+	if got := <-states; got == StateReady {
+		t.Fatalf("Library.WatchState() state = %v after broker stopped, want other than %v", got, StateReady)
+	}
+
+	// calls fail fast by default while the broker is away
+	payload := HashDataPayload{Profile: "Default", Input: []byte("Hello world")}
+	if _, err := lib.HashData(ctx, payload); status.Code(err) != codes.Unavailable {
+		t.Fatalf("Library.HashData() error = %v, want code %v", err, codes.Unavailable)
+	}
+
+	// calls made with WithWaitForReady wait until the broker is back
+	result := make(chan error, 1)
+	go func() {
+		_, err := lib.HashData(WithWaitForReady(ctx, true), payload)
+		result <- err
+	}()
+
+	select {
+	case err := <-result:
+		t.Fatalf("Library.HashData() returned %v before broker restarted", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	broker.start(t)
+
+	if err := lib.WaitForReady(ctx); err != nil {
+		t.Fatalf("Library.WaitForReady() error = %v", err)
+	}
+	if err := <-result; err != nil {
+		t.Fatalf("Library.HashData() waiting for ready error = %v", err)
+	}
+
+	for got := range states {
+		if got == StateReady {
+			break
+		}
+	}
+	if got := lib.State(); got != StateReady {
+		t.Fatalf("Library.State() = %v after broker restarted, want %v", got, StateReady)
+	}
+}
+
+func TestLibrary_WatchStateAfterClose(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx, WithEndpoint(broker.socket))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	states := lib.WatchState(ctx)
+	<-states
+
+	if err := lib.Close(); err != nil {
+		t.Fatalf("Library.Close() error = %v", err)
+	}
+
+	var last ConnectionState
+	for state := range states {
+		last = state
+	}
+	if last != StateShutdown {
+		t.Errorf("Library.WatchState() last state = %v, want %v", last, StateShutdown)
+	}
+
+	if got := lib.State(); got != StateShutdown {
+		t.Errorf("Library.State() = %v, want %v", got, StateShutdown)
+	}
+	if err := lib.WaitForReady(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("Library.WaitForReady() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestNew_LazyConnect(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ready := make(chan time.Duration, 1)
+	start := time.Now()
+	lib, err := New(ctx,
+		WithEndpoint(broker.socket),
+		WithConnectTimeout(5*time.Second),
+		WithLazyConnect(),
+		WithOnReady(func(elapsed time.Duration) { ready <- elapsed }),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	// the broker is not running yet, New must not wait for it
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("New() returned after %v in lazy mode", elapsed)
+	}
+	if got := lib.State(); got == StateReady {
+		t.Fatalf("Library.State() = %v before broker started", got)
+	}
+
+	broker.start(t)
+
+	payload := HashDataPayload{Profile: "Default", Input: []byte("Hello world")}
+	if _, err := lib.HashData(WithWaitForReady(ctx, true), payload); err != nil {
+		t.Fatalf("Library.HashData() error = %v", err)
+	}
+
+	select {
+	case elapsed := <-ready:
+		if elapsed <= 0 || elapsed > time.Since(start) {
+			t.Errorf("WithOnReady() elapsed = %v", elapsed)
+		}
+	case <-ctx.Done():
+		t.Fatal("WithOnReady() callback was not called")
+	}
+}
+
+func TestNew_ConnectTimeout(t *testing.T) {
+	broker := newTestBroker(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err := New(ctx,
+		WithEndpoint(broker.socket),
+		WithConnectTimeout(200*time.Millisecond),
+		WithOnReady(func(time.Duration) { t.Error("WithOnReady() callback called without broker") }),
+	)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("New() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("New() returned after %v, want about connect timeout", elapsed)
+	}
+}
@@ -2,9 +2,11 @@ package cryptobrokerclientgo
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"fmt"
-
-	"github.com/google/uuid"
+	"log/slog"
 
 	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
 )
@@ -18,8 +20,27 @@ const (
 
 var ErrInvalidHashOutputFormat = fmt.Errorf("invalid hash output format, must be either %v or %v", OutputFormatRaw, OutputFormatHex)
 
+// ErrHashMismatch is matched by HashMismatchError.
+var ErrHashMismatch = errors.New("hash does not match expected digest")
+
+// HashMismatchError reports input whose hash does not match the expected digest, see VerifyHash.
+type HashMismatchError struct {
+	Profile string
+
+	// Algorithm the server hashed the input with
+	Algorithm string
+}
+
+func (e *HashMismatchError) Error() string {
+	return fmt.Sprintf("%s hash of profile %q does not match expected digest", e.Algorithm, e.Profile)
+}
+
+func (e *HashMismatchError) Is(target error) bool {
+	return target == ErrHashMismatch
+}
+
 // HashingOpts defines all required data that need to be provided in order to invoke hashing.
-// The Metadata field is optional and will be created automatically if not provided.
+// The Metadata field is optional and will be created automatically if not provided, see WithMetadata.
 type HashDataPayload struct {
 	// Profile one of supported by crypto broker cryptogaphic profiles
 	Profile string
@@ -34,48 +55,24 @@ type HashDataPayload struct {
 	Metadata *Metadata
 }
 
-type TraceContext struct {
-	TraceId       string
-	SpanId        string
-	TraceFlags    string
-	TraceState    string
-	CorrelationId string
-}
-
-type Metadata struct {
-	Id           string
-	TraceContext *TraceContext
-}
-
 // HashData performs logic that results in hashing provided bytes using crypto broker.
 // As result it returns hash of provided bytes and non-nil error if any.
 func (lib *Library) HashData(ctx context.Context, payload HashDataPayload) (*protobuf.HashDataResponse, error) {
-
-	// Create the Metadata if not provided
-	if payload.Metadata == nil {
-		payload.Metadata = &Metadata{
-			Id: uuid.New().String(),
-		}
-	}
-	// Convert client TraceContext to protobuf TraceContext
-	var protoTraceContext *protobuf.TraceContext
-	if payload.Metadata.TraceContext != nil {
-		protoTraceContext = &protobuf.TraceContext{
-			TraceId:       payload.Metadata.TraceContext.TraceId,
-			SpanId:        payload.Metadata.TraceContext.SpanId,
-			TraceFlags:    payload.Metadata.TraceContext.TraceFlags,
-			TraceState:    payload.Metadata.TraceContext.TraceState,
-			CorrelationId: payload.Metadata.TraceContext.CorrelationId,
-		}
+	done, err := lib.begin()
+	if err != nil {
+		return nil, err
 	}
+	defer done()
 
+	return lib.hashData(ctx, payload)
+}
+
+// hashData is HashData of call already registered in flight.
+func (lib *Library) hashData(ctx context.Context, payload HashDataPayload) (*protobuf.HashDataResponse, error) {
 	req := &protobuf.HashDataRequest{
-		Profile: payload.Profile,
-		Input:   payload.Input,
-		Metadata: &protobuf.Metadata{
-			Id:           payload.Metadata.Id,
-			TraceContext: protoTraceContext,
-		},
+		Profile:  payload.Profile,
+		Input:    payload.Input,
+		Metadata: requestMetadata(ctx, payload.Metadata),
 	}
 
 	switch payload.OutputFormat {
@@ -89,3 +86,53 @@ func (lib *Library) HashData(ctx context.Context, payload HashDataPayload) (*pro
 
 	return lib.client.HashData(ctx, req)
 }
+
+// VerifyHash hashes input with profile and compares the digest with expected in constant time.
+// Expected is either the raw digest or its hex encoding, in lower or upper case.
+// It returns *HashMismatchError if the digests differ, and non-nil error if hashing failed.
+func (lib *Library) VerifyHash(ctx context.Context, profile string, input, expected []byte) error {
+	resp, err := lib.HashData(ctx, HashDataPayload{Profile: profile, Input: input, OutputFormat: OutputFormatRaw})
+	if err != nil {
+		return err
+	}
+
+	digest := resp.GetHashValueRaw()
+	if resp.GetHashValueHex() != "" {
+		if digest, err = hex.DecodeString(resp.GetHashValueHex()); err != nil {
+			return fmt.Errorf("decode hex digest: %w", err)
+		}
+	}
+
+	// hex encoding is twice as long as the raw digest, so that both cannot be confused
+	if len(digest) > 0 && len(expected) == 2*len(digest) {
+		decoded := make([]byte, len(digest))
+		if _, err := hex.Decode(decoded, expected); err == nil {
+			expected = decoded
+		}
+	}
+
+	if len(digest) == 0 || subtle.ConstantTimeCompare(digest, expected) != 1 {
+		return &HashMismatchError{Profile: profile, Algorithm: resp.GetHashAlgorithm()}
+	}
+
+	return nil
+}
+
+// InvalidateHashCache drops results of given profiles from the result cache, or all results if none are given,
+// e.g. after the profiles were reconfigured on the server. It does nothing unless the cache is enabled, see WithCache.
+func (lib *Library) InvalidateHashCache(profiles ...string) {
+	if lib.cache != nil {
+		lib.cache.Invalidate(profiles...)
+	}
+}
+
+// LogValue implements slog.LogValuer, so that hashed input never leaks into logs.
+// Only size of the input is logged.
+func (p HashDataPayload) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("profile", p.Profile),
+		slog.Int("inputSize", len(p.Input)),
+		slog.Any("outputFormat", p.OutputFormat),
+		slog.Any("metadata", p.Metadata),
+	)
+}
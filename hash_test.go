@@ -2,13 +2,18 @@ package cryptobrokerclientgo
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
 	"github.com/stretchr/testify/mock"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestLibrary_HashData(t *testing.T) {
@@ -141,3 +146,67 @@ func TestLibrary_HashData(t *testing.T) {
 		})
 	}
 }
+
+func TestLibrary_VerifyHash(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	lib, err := New(context.Background(), WithEndpoint(broker.socket))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	input := []byte("Hello world")
+	digest := sha256.Sum256(input)
+	other := sha256.Sum256([]byte("Hello there"))
+
+	tests := []struct {
+		name     string
+		expected []byte
+		wantErr  error
+	}{
+		{name: "VerifyHash() succeeds for raw digest", expected: digest[:]},
+		{name: "VerifyHash() succeeds for hex digest", expected: []byte(hex.EncodeToString(digest[:]))},
+		{name: "VerifyHash() succeeds for upper case hex digest", expected: []byte(strings.ToUpper(hex.EncodeToString(digest[:])))},
+		{name: "VerifyHash() fails for other raw digest", expected: other[:], wantErr: ErrHashMismatch},
+		{name: "VerifyHash() fails for other hex digest", expected: []byte(hex.EncodeToString(other[:])), wantErr: ErrHashMismatch},
+		{name: "VerifyHash() fails for truncated digest", expected: digest[:16], wantErr: ErrHashMismatch},
+		{name: "VerifyHash() fails for invalid hex digest", expected: []byte(strings.Repeat("z", 2*sha256.Size)), wantErr: ErrHashMismatch},
+		{name: "VerifyHash() fails for empty digest", expected: nil, wantErr: ErrHashMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := lib.VerifyHash(context.Background(), "Default", input, tt.expected)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Library.VerifyHash() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var mismatch *HashMismatchError
+			if tt.wantErr != nil && (!errors.As(err, &mismatch) || mismatch.Algorithm != "sha256" || mismatch.Profile != "Default") {
+				t.Errorf("Library.VerifyHash() error = %#v, want mismatch of sha256 hash of profile Default", err)
+			}
+		})
+	}
+
+	// raw digests returned by the server are compared as well
+	broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+		return &protobuf.HashDataResponse{
+			HashAlgorithm: "sha256",
+			HashValue:     &protobuf.HashDataResponse_HashValueRaw{HashValueRaw: digest[:]},
+			Metadata:      req.GetMetadata(),
+		}, nil
+	}
+	if err := lib.VerifyHash(context.Background(), "Default", input, []byte(hex.EncodeToString(digest[:]))); err != nil {
+		t.Errorf("Library.VerifyHash() error = %v for raw digest returned by the server", err)
+	}
+
+	// failures of hashing are not reported as mismatch
+	broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+		return nil, status.Error(codes.InvalidArgument, "unknown profile")
+	}
+	if err := lib.VerifyHash(context.Background(), "Unknown", input, digest[:]); status.Code(err) != codes.InvalidArgument || errors.Is(err, ErrHashMismatch) {
+		t.Errorf("Library.VerifyHash() error = %v, want code %v", err, codes.InvalidArgument)
+	}
+}
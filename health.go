@@ -18,8 +18,15 @@ type HealthDataResponse struct {
 	Status string
 }
 
-// HealthData checks the health status of the server
+// HealthData checks the health status of the server.
+// Status is unknown if the server can not be reached or the library is closed.
 func (lib *Library) HealthData(ctx context.Context) *HealthDataResponse {
+	done, err := lib.begin()
+	if err != nil {
+		return &HealthDataResponse{Status: StatusUnknown}
+	}
+	defer done()
+
 	resp, err := lib.healthClient.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
 	if err != nil {
 		return &HealthDataResponse{Status: StatusUnknown}
@@ -0,0 +1,302 @@
+// Package balancer spreads calls across multiple crypto broker endpoints of a single gRPC connection,
+// and across a pool of connections to every endpoint. Connections are health checked with the gRPC
+// health service and only those ready and serving receive calls, so that calls fail over to the remaining ones.
+package balancer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	_ "google.golang.org/grpc/health" // enables client-side health checking
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+// Supported load balancing policies.
+const (
+	// PolicyFailover sends all calls to the first endpoint in the list that is healthy.
+	PolicyFailover = "failover"
+
+	// PolicyWeighted spreads calls across healthy endpoints in proportion to their weights.
+	PolicyWeighted = "weighted"
+
+	// PolicyRoundRobin spreads calls evenly across healthy endpoints.
+	PolicyRoundRobin = "roundRobin"
+
+	// PolicyLeastOutstanding sends calls to the healthy endpoint with the fewest calls in flight.
+	PolicyLeastOutstanding = "leastOutstanding"
+)
+
+// Policies lists all supported load balancing policies.
+var Policies = []string{PolicyFailover, PolicyWeighted, PolicyRoundRobin, PolicyLeastOutstanding}
+
+// scheme of the resolver serving the endpoints, it is registered only with the connection using it.
+const scheme = "crypto-broker"
+
+type Endpoint struct {
+	// Endpoint path to Unix domain socket of the crypto broker server, or host:port with NetworkTCP
+	Endpoint string `yaml:"endpoint"`
+
+	// (Optional) Network of the endpoint, NetworkUnix if empty.
+	Network string `yaml:"network"`
+
+	// (Optional) TLS secures connections to the endpoint, only supported with NetworkTCP.
+	// Connections are not encrypted if nil.
+	TLS *TLSConfig `yaml:"tls"`
+
+	// (Optional) Weight of the endpoint with PolicyWeighted, 1 if zero.
+	Weight uint `yaml:"weight"`
+}
+
+type Config struct {
+	// Endpoints of the crypto broker servers in order of preference.
+	Endpoints []Endpoint `yaml:"endpoints"`
+
+	// (Optional) Policy choosing endpoint of every call, PolicyFailover if empty.
+	Policy string `yaml:"policy"`
+
+	// (Optional) HealthService is the service name sent in health checks, empty for the overall server health.
+	HealthService string `yaml:"healthService"`
+}
+
+func init() {
+	for _, policy := range Policies {
+		balancer.Register(base.NewBalancerBuilder(balancerName(policy), pickerBuilder{policy: policy}, base.Config{HealthCheck: true}))
+	}
+}
+
+func balancerName(policy string) string {
+	return "crypto_broker_" + policy
+}
+
+// DialOptions returns target and dial options of a connection balancing calls across endpoints in config,
+// opening poolSize connections to every endpoint. The options include dialer and transport credentials
+// connecting to every endpoint with its network and TLS settings.
+func DialOptions(config Config, poolSize int) (string, []grpc.DialOption, error) {
+	if len(config.Endpoints) == 0 {
+		return "", nil, errors.New("no endpoints")
+	}
+
+	policy := config.Policy
+	if policy == "" {
+		policy = PolicyFailover
+	}
+	if !slices.Contains(Policies, policy) {
+		return "", nil, fmt.Errorf("unknown load balancing policy %q", policy)
+	}
+
+	serviceConfig, err := json.Marshal(map[string]any{
+		"loadBalancingConfig": []map[string]any{{balancerName(policy): map[string]any{}}},
+		"healthCheckConfig":   map[string]any{"serviceName": config.HealthService},
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	poolSize = max(poolSize, 1)
+	addresses := make([]resolver.Address, 0, len(config.Endpoints)*poolSize)
+	for i, endpoint := range config.Endpoints {
+		if endpoint.Endpoint == "" {
+			return "", nil, fmt.Errorf("endpoint %d must not be empty", i)
+		}
+		if !slices.Contains(Networks, networkOf(endpoint)) {
+			return "", nil, fmt.Errorf("endpoint %d: unknown network %q", i, endpoint.Network)
+		}
+
+		creds, err := tlsCredentials(endpoint)
+		if err != nil {
+			return "", nil, fmt.Errorf("endpoint %d: %w", i, err)
+		}
+
+		info := &endpointInfo{index: i, weight: max(int(endpoint.Weight), 1)}
+		for conn := range poolSize {
+			// distinct attributes make gRPC open separate connection for every address
+			attrs := attributes.New(connKey{}, conn)
+			if creds != nil {
+				attrs = attrs.WithValue(tlsKey{}, creds)
+			}
+
+			addresses = append(addresses, resolver.Address{
+				Addr:               endpoint.Endpoint,
+				Attributes:         attrs,
+				BalancerAttributes: attributes.New(endpointKey{}, info),
+			})
+		}
+	}
+
+	r := manual.NewBuilderWithScheme(scheme)
+	r.InitialState(resolver.State{Addresses: addresses})
+
+	opts := []grpc.DialOption{
+		grpc.WithResolvers(r),
+		grpc.WithDefaultServiceConfig(string(serviceConfig)),
+		grpc.WithContextDialer(dialer(config.Endpoints)),
+		grpc.WithTransportCredentials(newTransportCredentials()),
+	}
+
+	return scheme + ":///broker", opts, nil
+}
+
+type (
+	endpointKey struct{}
+	connKey     struct{}
+)
+
+// endpointInfo is attached to addresses of all connections to an endpoint. It lives as long as
+// the connection, so that calls in flight are counted across pickers.
+type endpointInfo struct {
+	index       int
+	weight      int
+	outstanding atomic.Int64
+}
+
+func infoOf(address resolver.Address) *endpointInfo {
+	info, _ := address.BalancerAttributes.Value(endpointKey{}).(*endpointInfo)
+	if info == nil {
+		info = &endpointInfo{weight: 1}
+	}
+
+	return info
+}
+
+// readyEndpoint has at least one connection ready.
+type readyEndpoint struct {
+	info     *endpointInfo
+	subConns []balancer.SubConn
+	next     atomic.Uint32
+}
+
+// pick returns ready connections of the endpoint in turn.
+func (e *readyEndpoint) pick() balancer.SubConn {
+	return e.subConns[(e.next.Add(1)-1)%uint32(len(e.subConns))]
+}
+
+type pickerBuilder struct {
+	policy string
+}
+
+// Build returns picker over the endpoints having ready connections, which passed their health checks,
+// in order of the list.
+func (b pickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+
+	endpoints := map[*endpointInfo]*readyEndpoint{}
+	for subConn, subConnInfo := range info.ReadySCs {
+		endpointInfo := infoOf(subConnInfo.Address)
+		if endpoints[endpointInfo] == nil {
+			endpoints[endpointInfo] = &readyEndpoint{info: endpointInfo}
+		}
+
+		endpoints[endpointInfo].subConns = append(endpoints[endpointInfo].subConns, subConn)
+	}
+
+	ready := slices.SortedFunc(maps.Values(endpoints), func(a, b *readyEndpoint) int {
+		return a.info.index - b.info.index
+	})
+
+	switch b.policy {
+	case PolicyWeighted:
+		return &weightedPicker{ready: ready, current: make([]int, len(ready))}
+	case PolicyRoundRobin:
+		return newRoundRobinPicker(ready)
+	case PolicyLeastOutstanding:
+		return &leastOutstandingPicker{roundRobinPicker: newRoundRobinPicker(ready)}
+	default:
+		return failoverPicker{ready: ready[0]}
+	}
+}
+
+// failoverPicker picks the first ready endpoint.
+type failoverPicker struct {
+	ready *readyEndpoint
+}
+
+func (p failoverPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	return balancer.PickResult{SubConn: p.ready.pick()}, nil
+}
+
+// roundRobinPicker picks ready endpoints in turn, starting at random one.
+type roundRobinPicker struct {
+	ready []*readyEndpoint
+	next  atomic.Uint32
+}
+
+func newRoundRobinPicker(ready []*readyEndpoint) *roundRobinPicker {
+	p := &roundRobinPicker{ready: ready}
+	p.next.Store(rand.Uint32N(uint32(len(ready))))
+
+	return p
+}
+
+func (p *roundRobinPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	return balancer.PickResult{SubConn: p.ready[p.turn()].pick()}, nil
+}
+
+// turn returns index of the next endpoint.
+func (p *roundRobinPicker) turn() int {
+	return int((p.next.Add(1) - 1) % uint32(len(p.ready)))
+}
+
+// weightedPicker picks ready endpoints with smooth weighted round-robin,
+// which interleaves endpoints instead of sending bursts to each.
+type weightedPicker struct {
+	ready []*readyEndpoint
+
+	mu      sync.Mutex
+	current []int
+}
+
+func (p *weightedPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	best, total := 0, 0
+	for i, ready := range p.ready {
+		p.current[i] += ready.info.weight
+		total += ready.info.weight
+
+		if p.current[i] > p.current[best] {
+			best = i
+		}
+	}
+	p.current[best] -= total
+
+	return balancer.PickResult{SubConn: p.ready[best].pick()}, nil
+}
+
+// leastOutstandingPicker picks ready endpoint with the fewest calls in flight,
+// taking turns among equally loaded ones.
+type leastOutstandingPicker struct {
+	*roundRobinPicker
+}
+
+func (p *leastOutstandingPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	start := p.turn()
+
+	best := p.ready[start]
+	for i := 1; i < len(p.ready); i++ {
+		candidate := p.ready[(start+i)%len(p.ready)]
+		if candidate.info.outstanding.Load() < best.info.outstanding.Load() {
+			best = candidate
+		}
+	}
+
+	best.info.outstanding.Add(1)
+
+	return balancer.PickResult{
+		SubConn: best.pick(),
+		Done:    func(balancer.DoneInfo) { best.info.outstanding.Add(-1) },
+	}, nil
+}
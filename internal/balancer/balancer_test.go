@@ -0,0 +1,209 @@
+package balancer
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/resolver"
+)
+
+type fakeSubConn struct {
+	balancer.SubConn
+	name string
+}
+
+// buildPicker returns picker of policy over endpoints, all of them ready.
+func buildPicker(policy string, endpoints ...Endpoint) balancer.Picker {
+	info := base.PickerBuildInfo{ReadySCs: map[balancer.SubConn]base.SubConnInfo{}}
+	for i, endpoint := range endpoints {
+		address := resolver.Address{
+			Addr:               endpoint.Endpoint,
+			BalancerAttributes: attributes.New(endpointKey{}, &endpointInfo{index: i, weight: max(int(endpoint.Weight), 1)}),
+		}
+		info.ReadySCs[&fakeSubConn{name: endpoint.Endpoint}] = base.SubConnInfo{Address: address}
+	}
+
+	return pickerBuilder{policy: policy}.Build(info)
+}
+
+// pick returns names of endpoints picked by n calls, finishing calls only if done is set.
+func pick(t *testing.T, picker balancer.Picker, n int, done bool) []string {
+	t.Helper()
+
+	var names []string
+	for range n {
+		result, err := picker.Pick(balancer.PickInfo{})
+		if err != nil {
+			t.Fatalf("Pick() error = %v", err)
+		}
+
+		names = append(names, result.SubConn.(*fakeSubConn).name)
+		if done && result.Done != nil {
+			result.Done(balancer.DoneInfo{})
+		}
+	}
+
+	return names
+}
+
+func count(names []string) map[string]int {
+	counts := map[string]int{}
+	for _, name := range names {
+		counts[name]++
+	}
+
+	return counts
+}
+
+func TestPicker_Failover(t *testing.T) {
+	picker := buildPicker(PolicyFailover, Endpoint{Endpoint: "b"}, Endpoint{Endpoint: "a"}, Endpoint{Endpoint: "c"})
+
+	if got := count(pick(t, picker, 5, true)); !reflect.DeepEqual(got, map[string]int{"b": 5}) {
+		t.Errorf("picked %v, want only the first endpoint", got)
+	}
+}
+
+func TestPicker_Weighted(t *testing.T) {
+	picker := buildPicker(PolicyWeighted, Endpoint{Endpoint: "a", Weight: 5}, Endpoint{Endpoint: "b", Weight: 1}, Endpoint{Endpoint: "c"})
+
+	names := pick(t, picker, 14, true)
+	if got := count(names); !reflect.DeepEqual(got, map[string]int{"a": 10, "b": 2, "c": 2}) {
+		t.Errorf("picked %v, want in proportion of weights", got)
+	}
+
+	// smooth weighted round-robin interleaves the heavy endpoint with the others
+	want := []string{"a", "a", "b", "a", "c", "a", "a"}
+	if !reflect.DeepEqual(names[:7], want) {
+		t.Errorf("picked %v, want %v", names[:7], want)
+	}
+}
+
+func TestPicker_RoundRobin(t *testing.T) {
+	picker := buildPicker(PolicyRoundRobin, Endpoint{Endpoint: "a"}, Endpoint{Endpoint: "b"}, Endpoint{Endpoint: "c"})
+
+	names := pick(t, picker, 9, true)
+	if got := count(names); !reflect.DeepEqual(got, map[string]int{"a": 3, "b": 3, "c": 3}) {
+		t.Errorf("picked %v, want evenly", got)
+	}
+	for i := 1; i < len(names); i++ {
+		if names[i] == names[i-1] {
+			t.Errorf("picked %v, want endpoints in turn", names)
+			break
+		}
+	}
+}
+
+func TestPicker_LeastOutstanding(t *testing.T) {
+	picker := buildPicker(PolicyLeastOutstanding, Endpoint{Endpoint: "a"}, Endpoint{Endpoint: "b"})
+
+	// unfinished calls keep endpoints busy, so calls alternate
+	if got := count(pick(t, picker, 4, false)); !reflect.DeepEqual(got, map[string]int{"a": 2, "b": 2}) {
+		t.Errorf("picked %v, want evenly while calls are in flight", got)
+	}
+
+	// an endpoint slowed down by calls in flight is avoided
+	busy := pick(t, picker, 1, false)[0]
+	if got := count(pick(t, picker, 5, true)); got[busy] != 0 {
+		t.Errorf("picked %v, want none of the busier endpoint %q", got, busy)
+	}
+}
+
+func TestPicker_Pool(t *testing.T) {
+	first, second := &endpointInfo{index: 0, weight: 1}, &endpointInfo{index: 1, weight: 1}
+	info := base.PickerBuildInfo{ReadySCs: map[balancer.SubConn]base.SubConnInfo{}}
+	for _, name := range []string{"a1", "a2", "b1", "b2"} {
+		endpoint := first
+		if name[0] == 'b' {
+			endpoint = second
+		}
+
+		address := resolver.Address{Addr: name[:1], BalancerAttributes: attributes.New(endpointKey{}, endpoint)}
+		info.ReadySCs[&fakeSubConn{name: name}] = base.SubConnInfo{Address: address}
+	}
+
+	tests := []struct {
+		policy string
+		want   map[string]int
+	}{
+		{policy: PolicyFailover, want: map[string]int{"a1": 4, "a2": 4}},
+		{policy: PolicyRoundRobin, want: map[string]int{"a1": 2, "a2": 2, "b1": 2, "b2": 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			picker := pickerBuilder{policy: tt.policy}.Build(info)
+
+			if got := count(pick(t, picker, 8, true)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("picked %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPicker_NoneReady(t *testing.T) {
+	picker := buildPicker(PolicyRoundRobin)
+
+	if _, err := picker.Pick(balancer.PickInfo{}); err != balancer.ErrNoSubConnAvailable {
+		t.Errorf("Pick() error = %v, want %v", err, balancer.ErrNoSubConnAvailable)
+	}
+}
+
+func TestDialOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{
+			name:   "DialOptions() defaults to failover",
+			config: Config{Endpoints: []Endpoint{{Endpoint: "/tmp/a.sock"}}},
+		},
+		{
+			name:    "DialOptions() fails without endpoints",
+			config:  Config{Policy: PolicyRoundRobin},
+			wantErr: true,
+		},
+		{
+			name:    "DialOptions() fails for empty endpoint",
+			config:  Config{Endpoints: []Endpoint{{Endpoint: "/tmp/a.sock"}, {}}},
+			wantErr: true,
+		},
+		{
+			name:   "DialOptions() accepts TCP endpoint with TLS",
+			config: Config{Endpoints: []Endpoint{{Endpoint: "/tmp/a.sock"}, {Endpoint: "broker.example:8443", Network: NetworkTCP, TLS: &TLSConfig{}}}},
+		},
+		{
+			name:    "DialOptions() fails for unknown network",
+			config:  Config{Endpoints: []Endpoint{{Endpoint: "/tmp/a.sock", Network: "udp"}}},
+			wantErr: true,
+		},
+		{
+			name:    "DialOptions() fails for TLS over Unix socket",
+			config:  Config{Endpoints: []Endpoint{{Endpoint: "/tmp/a.sock", TLS: &TLSConfig{}}}},
+			wantErr: true,
+		},
+		{
+			name:    "DialOptions() fails for missing TLS CA file",
+			config:  Config{Endpoints: []Endpoint{{Endpoint: "broker.example:8443", Network: NetworkTCP, TLS: &TLSConfig{CAFile: "/nonexistent/ca.pem"}}}},
+			wantErr: true,
+		},
+		{
+			name:    "DialOptions() fails for unknown policy",
+			config:  Config{Endpoints: []Endpoint{{Endpoint: "/tmp/a.sock"}}, Policy: "random"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, opts, err := DialOptions(tt.config, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DialOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (target == "" || len(opts) == 0) {
+				t.Errorf("DialOptions() = %q, %d options", target, len(opts))
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package balancer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Supported networks of endpoints.
+const (
+	// NetworkUnix connects to Unix domain socket at path of the endpoint.
+	NetworkUnix = "unix"
+
+	// NetworkTCP connects to host:port of the endpoint.
+	NetworkTCP = "tcp"
+)
+
+// Networks lists all supported networks of endpoints.
+var Networks = []string{NetworkUnix, NetworkTCP}
+
+type TLSConfig struct {
+	// (Optional) CAFile path to PEM encoded certificates of CAs verifying the server, system roots are used if empty.
+	CAFile string `yaml:"caFile"`
+
+	// (Optional) CertFile and KeyFile paths to PEM encoded client certificate and its key, for mutual TLS.
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+
+	// (Optional) ServerName expected in the server certificate, host of the endpoint if empty.
+	ServerName string `yaml:"serverName"`
+}
+
+type tlsKey struct{}
+
+// tlsEndpoint holds TLS credentials of an endpoint and the server name expected in its certificate.
+type tlsEndpoint struct {
+	creds      credentials.TransportCredentials
+	serverName string
+}
+
+// networkOf returns network of endpoint, NetworkUnix if not set.
+func networkOf(endpoint Endpoint) string {
+	if endpoint.Network == "" {
+		return NetworkUnix
+	}
+
+	return endpoint.Network
+}
+
+// tlsCredentials returns credentials securing connections to endpoint with TLS, or nil if it is not configured.
+func tlsCredentials(endpoint Endpoint) (*tlsEndpoint, error) {
+	if endpoint.TLS == nil {
+		return nil, nil
+	}
+	if networkOf(endpoint) != NetworkTCP {
+		return nil, fmt.Errorf("tls is only supported with network %q", NetworkTCP)
+	}
+
+	serverName := endpoint.TLS.ServerName
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(endpoint.Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("server name of %q: %w", endpoint.Endpoint, err)
+		}
+
+		serverName = host
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if endpoint.TLS.CAFile != "" {
+		pem, err := os.ReadFile(endpoint.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read tls ca file: %w", err)
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in tls ca file %s", endpoint.TLS.CAFile)
+		}
+	}
+
+	if endpoint.TLS.CertFile != "" || endpoint.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(endpoint.TLS.CertFile, endpoint.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load tls client certificate: %w", err)
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return &tlsEndpoint{creds: credentials.NewTLS(config), serverName: serverName}, nil
+}
+
+// dialer returns dialer connecting to addresses of endpoints with their networks.
+func dialer(endpoints []Endpoint) func(context.Context, string) (net.Conn, error) {
+	networks := make(map[string]string, len(endpoints))
+	for _, endpoint := range endpoints {
+		networks[endpoint.Endpoint] = networkOf(endpoint)
+	}
+
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, networks[addr], addr)
+	}
+}
+
+// transportCredentials secures connections to endpoints configured with TLS, while the others are not encrypted,
+// e.g. local Unix socket with fallback to remote server. Credentials of an endpoint are attached to its addresses.
+type transportCredentials struct {
+	insecure credentials.TransportCredentials
+}
+
+func newTransportCredentials() credentials.TransportCredentials {
+	return transportCredentials{insecure: insecure.NewCredentials()}
+}
+
+func (c transportCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	// TLS credentials verify the authority, which is the same for all endpoints of the connection
+	if endpoint, ok := credentials.ClientHandshakeInfoFromContext(ctx).Attributes.Value(tlsKey{}).(*tlsEndpoint); ok {
+		return endpoint.creds.ClientHandshake(ctx, endpoint.serverName, conn)
+	}
+
+	return c.insecure.ClientHandshake(ctx, authority, conn)
+}
+
+func (c transportCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("server handshake is not supported")
+}
+
+func (c transportCredentials) Info() credentials.ProtocolInfo {
+	return c.insecure.Info()
+}
+
+func (c transportCredentials) Clone() credentials.TransportCredentials {
+	return transportCredentials{insecure: c.insecure.Clone()}
+}
+
+func (c transportCredentials) OverrideServerName(string) error {
+	return nil
+}
@@ -0,0 +1,241 @@
+package interceptor
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/metrics"
+)
+
+type CacheConfig struct {
+	// Methods lists full gRPC method names whose results are cached, e.g. "/CryptoBroker.CryptoGrpc/HashData".
+	// Results are cached per request apart from Metadata, e.g. per profile, output format and fingerprint of input.
+	Methods []string `yaml:"methods"`
+
+	// MaxBytes caps the size of cached results, least recently used results are evicted first.
+	// Zero disables the cache.
+	MaxBytes int `yaml:"maxBytes"`
+
+	// TTL of cached results, e.g. "10m", required when the cache is enabled. Changes of profiles on the server
+	// are only noticed on misses, so the TTL bounds how long results of the previous profile are served.
+	TTL string `yaml:"ttl"`
+}
+
+// cacheEntry is a successful result cached for a request.
+type cacheEntry struct {
+	key     string
+	profile string
+	reply   proto.Message
+	size    int
+	expires time.Time
+}
+
+// Cache holds results of successful calls, so that repeated calls are answered without reaching the server.
+// Results of a profile are invalidated when the server reports another hash algorithm for it on a miss,
+// or on demand. Results expire after TTL, so that changes of profiles are noticed even for hot entries.
+type Cache struct {
+	config  CacheConfig
+	ttl     time.Duration
+	options options
+
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element // of *cacheEntry
+	lru     *list.List               // most recently used first
+
+	// algorithms reported by the server per profile
+	algorithms map[string]string
+
+	// generation is increased by every invalidation, so that results of calls in flight are not cached afterwards
+	generation uint64
+}
+
+// Create and return result cache, its interceptor is returned by Interceptor.
+// Hits and misses are counted in metrics given with WithMetrics.
+func NewCache(config CacheConfig, opts ...Option) (*Cache, error) {
+	c := &Cache{
+		config:     config,
+		options:    newOptions(opts),
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		algorithms: make(map[string]string),
+	}
+
+	if config.MaxBytes < 0 {
+		return nil, fmt.Errorf("cache max bytes must not be negative, got %d", config.MaxBytes)
+	}
+
+	if config.MaxBytes == 0 {
+		return c, nil
+	}
+
+	ttl, err := time.ParseDuration(config.TTL)
+	if err != nil {
+		return nil, fmt.Errorf("parse cache ttl: %w", err)
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("cache ttl must be positive, got %v", ttl)
+	}
+
+	c.ttl = ttl
+
+	return c, nil
+}
+
+// Interceptor returns interceptor answering calls from the cache.
+func (c *Cache) Interceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req any,
+		reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		msg, ok := reply.(proto.Message)
+		r, isProto := req.(proto.Message)
+		if !ok || !isProto || c.config.MaxBytes == 0 || !slices.Contains(c.config.Methods, method) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		key, err := contentKey(method, r)
+		if err != nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		profile := profileOf(req)
+		labels := []metrics.Label{
+			{Key: metrics.LabelMethod, Value: method},
+			{Key: metrics.LabelProfile, Value: profile},
+		}
+
+		cached, generation := c.get(key)
+		if cached != nil {
+			c.options.measure().Add(metrics.CacheHits, 1, labels...)
+
+			proto.Reset(msg)
+			proto.Merge(msg, cached)
+			echoMetadata(r, msg)
+
+			return nil
+		}
+
+		c.options.measure().Add(metrics.CacheMisses, 1, labels...)
+
+		if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+			return err
+		}
+
+		c.put(key, profile, msg, generation)
+
+		return nil
+	}
+}
+
+// Invalidate drops cached results of given profiles, or all results if none are given.
+func (c *Cache) Invalidate(profiles ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	if len(profiles) == 0 {
+		c.entries = make(map[string]*list.Element)
+		c.lru.Init()
+		c.size = 0
+		clear(c.algorithms)
+
+		return
+	}
+
+	for _, profile := range profiles {
+		c.invalidateProfile(profile)
+		delete(c.algorithms, profile)
+	}
+}
+
+// get returns copy of result cached for key, or nil, and the current generation of the cache.
+func (c *Cache) get(key string) (proto.Message, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, c.generation
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if !c.options.timeSource().Now().Before(entry.expires) {
+		c.remove(element)
+		return nil, c.generation
+	}
+
+	c.lru.MoveToFront(element)
+
+	return proto.Clone(entry.reply), c.generation
+}
+
+// put caches reply for key, unless the cache was invalidated since generation.
+func (c *Cache) put(key, profile string, reply proto.Message, generation uint64) {
+	// metadata is echoed per call, it is not part of the result
+	entry := &cacheEntry{key: key, profile: profile, reply: proto.Clone(reply)}
+	setMetadata(entry.reply, nil)
+
+	entry.size = len(key) + proto.Size(entry.reply)
+	entry.expires = c.options.timeSource().Now().Add(c.ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	// the server reporting another algorithm for the profile means that the profile changed
+	if r, ok := reply.(interface{ GetHashAlgorithm() string }); ok {
+		if algorithm, known := c.algorithms[profile]; known && algorithm != r.GetHashAlgorithm() {
+			c.invalidateProfile(profile)
+		}
+
+		c.algorithms[profile] = r.GetHashAlgorithm()
+	}
+
+	if entry.size > c.config.MaxBytes {
+		return
+	}
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+	c.size += entry.size
+
+	for c.size > c.config.MaxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Cache) invalidateProfile(profile string) {
+	for element := c.lru.Front(); element != nil; {
+		next := element.Next()
+		if element.Value.(*cacheEntry).profile == profile {
+			c.remove(element)
+		}
+		element = next
+	}
+}
+
+func (c *Cache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
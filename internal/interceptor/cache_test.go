@@ -0,0 +1,238 @@
+package interceptor
+
+import (
+	"context"
+	"crypto/sha256"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/metrics"
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+)
+
+// hashServer answers hash requests with the input as hash, reporting algorithm, and counts calls.
+type hashServer struct {
+	algorithm string
+	calls     int
+	err       error
+}
+
+func (s *hashServer) invoke(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	s.calls++
+	if s.err != nil {
+		return s.err
+	}
+
+	r := req.(*protobuf.HashDataRequest)
+	resp := reply.(*protobuf.HashDataResponse)
+	resp.HashAlgorithm = s.algorithm
+	resp.HashValue = &protobuf.HashDataResponse_HashValueHex{HashValueHex: string(r.GetInput())}
+	resp.Metadata = r.GetMetadata()
+
+	return nil
+}
+
+// hash calls interceptor with hash request and returns the hash.
+func hash(t *testing.T, interceptor grpc.UnaryClientInterceptor, server *hashServer, id, profile, input string) string {
+	t.Helper()
+
+	reply := &protobuf.HashDataResponse{}
+	if err := interceptor(context.Background(), "/svc/Hash", hashRequest(id, profile, input), reply, nil, server.invoke); err != nil {
+		t.Fatalf("interceptor() error = %v", err)
+	}
+	if reply.GetMetadata().GetId() != id {
+		t.Errorf("reply echoes id %q, want %q", reply.GetMetadata().GetId(), id)
+	}
+
+	return reply.GetHashValueHex()
+}
+
+func TestCache_HitsAndMisses(t *testing.T) {
+	m := metrics.NewMemory()
+	cache, err := NewCache(CacheConfig{Methods: []string{"/svc/Hash"}, MaxBytes: 1 << 20, TTL: "1m"}, WithMetrics(m))
+	if err != nil {
+		t.Fatalf("NewCache() error = %v", err)
+	}
+
+	interceptor := cache.Interceptor()
+	server := &hashServer{algorithm: "sha256"}
+
+	for i, id := range []string{"1", "2", "3"} {
+		if got := hash(t, interceptor, server, id, "Default", "blob"); got != "blob" {
+			t.Errorf("call %d: hash = %q, want %q", i, got, "blob")
+		}
+	}
+	if server.calls != 1 {
+		t.Errorf("calls = %d, want 1", server.calls)
+	}
+
+	// other inputs and profiles are cached separately
+	hash(t, interceptor, server, "4", "Default", "other blob")
+	hash(t, interceptor, server, "5", "HSM", "blob")
+	if server.calls != 3 {
+		t.Errorf("calls = %d, want 3", server.calls)
+	}
+
+	labels := []metrics.Label{{Key: metrics.LabelMethod, Value: "/svc/Hash"}, {Key: metrics.LabelProfile, Value: "Default"}}
+	if hits, misses := m.Counter(metrics.CacheHits, labels...), m.Counter(metrics.CacheMisses, labels...); hits != 2 || misses != 2 {
+		t.Errorf("hits = %v, misses = %v, want 2 and 2", hits, misses)
+	}
+
+	// failures are not cached
+	server.err = status.Error(codes.Unavailable, "down")
+	for range 2 {
+		err := interceptor(context.Background(), "/svc/Hash", hashRequest("6", "Default", "new blob"), &protobuf.HashDataResponse{}, nil, server.invoke)
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("interceptor() error = %v, want code %v", err, codes.Unavailable)
+		}
+	}
+	if server.calls != 5 {
+		t.Errorf("calls = %d, want 5", server.calls)
+	}
+}
+
+func TestCache_Eviction(t *testing.T) {
+	clock := newFakeClock()
+
+	// the size of a cached result is the size of the key and the encoded reply
+	entrySize := len("/svc/Hash\x00") + sha256.Size + proto.Size(&protobuf.HashDataResponse{
+		HashAlgorithm: "sha256",
+		HashValue:     &protobuf.HashDataResponse_HashValueHex{HashValueHex: strings.Repeat("a", 1000)},
+	})
+	cache, err := NewCache(CacheConfig{Methods: []string{"/svc/Hash"}, MaxBytes: 2 * entrySize, TTL: "1m"}, WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewCache() error = %v", err)
+	}
+
+	interceptor := cache.Interceptor()
+	server := &hashServer{algorithm: "sha256"}
+	input := func(c string) string { return strings.Repeat(c, 1000) }
+
+	hash(t, interceptor, server, "1", "Default", input("a"))
+	hash(t, interceptor, server, "2", "Default", input("b"))
+	hash(t, interceptor, server, "3", "Default", input("a"))
+	if server.calls != 2 {
+		t.Fatalf("calls = %d, want 2 while results fit", server.calls)
+	}
+
+	// the least recently used result is evicted
+	hash(t, interceptor, server, "4", "Default", input("c"))
+	hash(t, interceptor, server, "5", "Default", input("a"))
+	if server.calls != 3 {
+		t.Errorf("calls = %d, want 3 after eviction of b", server.calls)
+	}
+	hash(t, interceptor, server, "6", "Default", input("b"))
+	if server.calls != 4 {
+		t.Errorf("calls = %d, want 4 for evicted b", server.calls)
+	}
+
+	// results expire after TTL
+	clock.Advance(time.Minute)
+	hash(t, interceptor, server, "7", "Default", input("b"))
+	if server.calls != 5 {
+		t.Errorf("calls = %d, want 5 for expired b", server.calls)
+	}
+
+	// results larger than the cache are not cached
+	hash(t, interceptor, server, "8", "Default", input("d")+input("d")+input("d"))
+	hash(t, interceptor, server, "9", "Default", input("d")+input("d")+input("d"))
+	if server.calls != 7 {
+		t.Errorf("calls = %d, want 7 for result larger than the cache", server.calls)
+	}
+}
+
+func TestCache_Invalidation(t *testing.T) {
+	cache, err := NewCache(CacheConfig{Methods: []string{"/svc/Hash"}, MaxBytes: 1 << 20, TTL: "1m"})
+	if err != nil {
+		t.Fatalf("NewCache() error = %v", err)
+	}
+
+	interceptor := cache.Interceptor()
+	server := &hashServer{algorithm: "sha256"}
+
+	fill := func() {
+		hash(t, interceptor, server, "1", "Default", "a")
+		hash(t, interceptor, server, "2", "Default", "b")
+		hash(t, interceptor, server, "3", "HSM", "a")
+	}
+	fill()
+
+	// on demand, for a profile or entirely
+	cache.Invalidate("HSM")
+	fill()
+	if server.calls != 4 {
+		t.Errorf("calls = %d, want 4 after invalidation of profile", server.calls)
+	}
+	cache.Invalidate()
+	fill()
+	if server.calls != 7 {
+		t.Errorf("calls = %d, want 7 after invalidation of all", server.calls)
+	}
+
+	// server reporting another algorithm for the profile invalidates its results
+	server.algorithm = "sha3-256"
+	hash(t, interceptor, server, "4", "Default", "c")
+	fill()
+	if server.calls != 10 {
+		t.Errorf("calls = %d, want 10 after algorithm of Default changed", server.calls)
+	}
+}
+
+func TestCache_ProfileChangeOfHotEntry(t *testing.T) {
+	clock := newFakeClock()
+	cache, err := NewCache(CacheConfig{Methods: []string{"/svc/Hash"}, MaxBytes: 1 << 20, TTL: "1m"}, WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewCache() error = %v", err)
+	}
+
+	interceptor := cache.Interceptor()
+	server := &hashServer{algorithm: "sha256"}
+	algorithm := func() string {
+		reply := &protobuf.HashDataResponse{}
+		if err := interceptor(context.Background(), "/svc/Hash", hashRequest("1", "Default", "a"), reply, nil, server.invoke); err != nil {
+			t.Fatalf("interceptor() error = %v", err)
+		}
+
+		return reply.GetHashAlgorithm()
+	}
+
+	algorithm()
+	server.algorithm = "sha3-256"
+
+	// the entry is hit until it expires, the server is not asked in between
+	clock.Advance(30 * time.Second)
+	if got := algorithm(); got != "sha256" {
+		t.Errorf("algorithm = %q before expiry, want sha256", got)
+	}
+	clock.Advance(30 * time.Second)
+	if got := algorithm(); got != "sha3-256" {
+		t.Errorf("algorithm = %q after expiry, want sha3-256", got)
+	}
+}
+
+func TestCache_Disabled(t *testing.T) {
+	cache, err := NewCache(CacheConfig{Methods: []string{"/svc/Hash"}})
+	if err != nil {
+		t.Fatalf("NewCache() error = %v", err)
+	}
+
+	server := &hashServer{}
+	for range 2 {
+		hash(t, cache.Interceptor(), server, "1", "Default", "a")
+	}
+	if server.calls != 2 {
+		t.Errorf("calls = %d, want 2", server.calls)
+	}
+
+	for _, ttl := range []string{"soon", "", "0s"} {
+		if _, err := NewCache(CacheConfig{MaxBytes: 1, TTL: ttl}); err == nil {
+			t.Errorf("NewCache() error = nil for ttl %q", ttl)
+		}
+	}
+}
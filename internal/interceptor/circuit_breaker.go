@@ -5,12 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/sony/gobreaker/v2"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/metrics"
 )
 
 var (
@@ -18,6 +23,11 @@ var (
 	ErrCircuitHalfOpen = errors.New("circuit breaker half-open limit reached")
 )
 
+// DefaultExcludedMethods lists the methods bypassing the circuit breaker when
+// CircuitConfig.ExcludedMethods is nil. Health checks must keep reporting the
+// real server state, so the whole health service is excluded.
+var DefaultExcludedMethods = []string{"/grpc.health.v1.Health/"}
+
 type CircuitConfig struct {
 	Name                string       `yaml:"name"`
 	MaxRequests         uint32       `yaml:"maxRequests"`
@@ -25,21 +35,173 @@ type CircuitConfig struct {
 	Timeout             string       `yaml:"timeout"`
 	ConsecutiveFailures uint32       `yaml:"consecutiveFailures"`
 	FailureStatusCodes  []codes.Code `yaml:"failureStatusCodes"`
+
+	// Methods overrides the settings above for full gRPC method names,
+	// e.g. "/CryptoBroker.CryptoGrpc/SignCertificate". Zero-valued fields are inherited.
+	Methods map[string]CircuitConfig `yaml:"methods"`
+
+	// Profiles overrides the settings for requests using the given crypto broker profile.
+	// Profile overrides are applied on top of method overrides and get a breaker of their own.
+	Profiles map[string]CircuitConfig `yaml:"profiles"`
+
+	// PerProfile keeps separate breaker state for every profile of a method.
+	PerProfile bool `yaml:"perProfile"`
+
+	// ExcludedMethods lists full method names that bypass the breaker entirely.
+	// Entries ending with "/" match every method of a service.
+	// When nil, DefaultExcludedMethods is used.
+	ExcludedMethods []string `yaml:"excludedMethods"`
+}
+
+// merge returns copy of c with all non-zero settings of override applied.
+func (c CircuitConfig) merge(override CircuitConfig) CircuitConfig {
+	if override.Name != "" {
+		c.Name = override.Name
+	}
+	if override.MaxRequests != 0 {
+		c.MaxRequests = override.MaxRequests
+	}
+	if override.Interval != "" {
+		c.Interval = override.Interval
+	}
+	if override.Timeout != "" {
+		c.Timeout = override.Timeout
+	}
+	if override.ConsecutiveFailures != 0 {
+		c.ConsecutiveFailures = override.ConsecutiveFailures
+	}
+	if override.FailureStatusCodes != nil {
+		c.FailureStatusCodes = override.FailureStatusCodes
+	}
+
+	return c
+}
+
+// circuitBreakers holds lazily created breakers, one per method (and profile if requested).
+type circuitBreakers struct {
+	config   CircuitConfig
+	settings map[policyKey]gobreaker.Settings
+	excluded []string
+	options  options
+
+	mu       sync.Mutex
+	breakers map[policyKey]*gobreaker.CircuitBreaker[any]
 }
 
 // Create and return circuit breaker interceptor
-func CircuitBreaker(config CircuitConfig) (grpc.UnaryClientInterceptor, error) {
+func CircuitBreaker(config CircuitConfig, opts ...Option) (grpc.UnaryClientInterceptor, error) {
+	o := newOptions(opts)
+	cbs := &circuitBreakers{
+		config:   config,
+		settings: make(map[policyKey]gobreaker.Settings),
+		excluded: config.ExcludedMethods,
+		options:  o,
+		breakers: make(map[policyKey]*gobreaker.CircuitBreaker[any]),
+	}
+	if cbs.excluded == nil {
+		cbs.excluded = DefaultExcludedMethods
+	}
+
+	for _, key := range policyKeys(config.Methods, config.Profiles) {
+		resolved := config
+		if override, ok := config.Methods[key.method]; ok {
+			resolved = resolved.merge(override)
+		}
+		if override, ok := config.Profiles[key.profile]; ok {
+			resolved = resolved.merge(override)
+		}
+
+		settings, err := breakerSettings(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+
+		cbs.settings[key] = settings
+	}
+
+	interceptor := func(
+		ctx context.Context,
+		method string,
+		req any,
+		reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if matchesMethod(cbs.excluded, method) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		breaker := cbs.get(method, profileOf(req))
+		_, err := breaker.Execute(func() (any, error) {
+			return nil, invoker(ctx, method, req, reply, cc, opts...)
+		})
+
+		switch {
+		case errors.Is(err, gobreaker.ErrOpenState):
+			return ErrCircuitOpen
+		case errors.Is(err, gobreaker.ErrTooManyRequests):
+			return ErrCircuitHalfOpen
+		default:
+			return err
+		}
+	}
+
+	return interceptor, nil
+}
+
+// get returns breaker guarding given method and profile, creating it on first use.
+func (cbs *circuitBreakers) get(method, profile string) *gobreaker.CircuitBreaker[any] {
+	settingsKey := lookupKey(cbs.config.Methods, cbs.config.Profiles, method, profile)
+
+	stateKey := policyKey{method: method}
+	if cbs.config.PerProfile || settingsKey.profile != "" {
+		stateKey.profile = profile
+	}
+
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	breaker, ok := cbs.breakers[stateKey]
+	if !ok {
+		settings := cbs.settings[settingsKey]
+		labels := []metrics.Label{
+			{Key: metrics.LabelName, Value: settings.Name},
+			{Key: metrics.LabelMethod, Value: stateKey.method},
+			{Key: metrics.LabelProfile, Value: stateKey.profile},
+		}
+
+		settings.Name = fmt.Sprintf("%s:%s", settings.Name, stateKey)
+		settings.OnStateChange = func(name string, from, to gobreaker.State) {
+			cbs.options.log().Warn("circuit breaker state changed",
+				slog.String("name", name),
+				slog.String("from", from.String()),
+				slog.String("to", to.String()),
+			)
+			cbs.options.measure().Set(metrics.CircuitState, float64(to), labels...)
+		}
+
+		breaker = gobreaker.NewCircuitBreaker[any](settings)
+		cbs.breakers[stateKey] = breaker
+		cbs.options.measure().Set(metrics.CircuitState, float64(breaker.State()), labels...)
+	}
+
+	return breaker
+}
+
+// breakerSettings translates config into gobreaker settings.
+func breakerSettings(config CircuitConfig) (gobreaker.Settings, error) {
 	interval, err := time.ParseDuration(config.Interval)
 	if err != nil {
-		return nil, fmt.Errorf("parse circuit breaker interval: %w", err)
+		return gobreaker.Settings{}, fmt.Errorf("parse circuit breaker interval: %w", err)
 	}
 
 	timeout, err := time.ParseDuration(config.Timeout)
 	if err != nil {
-		return nil, fmt.Errorf("parse circuit breaker timeout: %w", err)
+		return gobreaker.Settings{}, fmt.Errorf("parse circuit breaker timeout: %w", err)
 	}
 
-	breaker := gobreaker.NewCircuitBreaker[any](gobreaker.Settings{
+	return gobreaker.Settings{
 		Name:        config.Name,
 		MaxRequests: config.MaxRequests,
 		Interval:    interval,
@@ -64,37 +226,21 @@ func CircuitBreaker(config CircuitConfig) (grpc.UnaryClientInterceptor, error) {
 			return true
 		},
 
-		OnStateChange: func(name string, from, to gobreaker.State) {
-			slog.Warn("circuit breaker state changed",
-				slog.String("name", name),
-				slog.String("from", from.String()),
-				slog.String("to", to.String()),
-			)
+		// cancelled calls, e.g. losing attempts of hedged calls, tell nothing about the server
+		IsExcluded: func(err error) bool {
+			return status.Code(err) == codes.Canceled && !slices.Contains(config.FailureStatusCodes, codes.Canceled)
 		},
-	})
-
-	interceptor := func(
-		ctx context.Context,
-		method string,
-		req any,
-		reply any,
-		cc *grpc.ClientConn,
-		invoker grpc.UnaryInvoker,
-		opts ...grpc.CallOption,
-	) error {
-		_, err := breaker.Execute(func() (any, error) {
-			return nil, invoker(ctx, method, req, reply, cc, opts...)
-		})
+	}, nil
+}
 
-		switch {
-		case errors.Is(err, gobreaker.ErrOpenState):
-			return ErrCircuitOpen
-		case errors.Is(err, gobreaker.ErrTooManyRequests):
-			return ErrCircuitHalfOpen
-		default:
-			return err
+// matchesMethod reports whether method is listed in patterns.
+// Patterns ending with "/" match every method of the service.
+func matchesMethod(patterns []string, method string) bool {
+	for _, p := range patterns {
+		if p == method || (strings.HasSuffix(p, "/") && strings.HasPrefix(method, p)) {
+			return true
 		}
 	}
 
-	return interceptor, nil
+	return false
 }
@@ -1,7 +1,11 @@
 package interceptor
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"log/slog"
+	"strings"
 	"testing"
 	"time"
 
@@ -83,3 +87,148 @@ func TestCircuitBreaker_StateChanges(t *testing.T) {
 		t.Fatalf("expected closed circuit success, got %v", err)
 	}
 }
+
+func TestCircuitBreaker_PerMethod(t *testing.T) {
+	cfg := CircuitConfig{
+		Name:                "test",
+		MaxRequests:         1,
+		Interval:            "30s",
+		Timeout:             "1m",
+		ConsecutiveFailures: 1,
+		FailureStatusCodes:  []codes.Code{14},
+		Methods: map[string]CircuitConfig{
+			"/test.Service/Tolerant": {ConsecutiveFailures: 2},
+		},
+	}
+
+	interceptor, err := CircuitBreaker(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := context.Background()
+
+	fail :=
+		func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return status.Error(codes.Unavailable, "failure")
+		}
+
+	success :=
+		func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return nil
+		}
+
+	// failing method opens its own circuit.
+	_ = interceptor(ctx, "/test.Service/Sign", nil, nil, nil, fail)
+	if err = interceptor(ctx, "/test.Service/Sign", nil, nil, nil, success); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected open circuit error, got %v", err)
+	}
+
+	// other methods are not affected.
+	if err = interceptor(ctx, "/test.Service/Hash", nil, nil, nil, success); err != nil {
+		t.Fatalf("expected success for independent method, got %v", err)
+	}
+
+	// method override tolerates one more failure.
+	_ = interceptor(ctx, "/test.Service/Tolerant", nil, nil, nil, fail)
+	if err = interceptor(ctx, "/test.Service/Tolerant", nil, nil, nil, success); err != nil {
+		t.Fatalf("expected success below overridden threshold, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_PerProfile(t *testing.T) {
+	cfg := CircuitConfig{
+		Name:                "test",
+		MaxRequests:         1,
+		Interval:            "30s",
+		Timeout:             "1m",
+		ConsecutiveFailures: 1,
+		FailureStatusCodes:  []codes.Code{14},
+		PerProfile:          true,
+	}
+
+	interceptor, err := CircuitBreaker(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := context.Background()
+
+	fail :=
+		func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return status.Error(codes.Unavailable, "failure")
+		}
+
+	success :=
+		func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return nil
+		}
+
+	_ = interceptor(ctx, "/test.Service/Sign", profileRequest("HSM"), nil, nil, fail)
+	if err = interceptor(ctx, "/test.Service/Sign", profileRequest("HSM"), nil, nil, success); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected open circuit error, got %v", err)
+	}
+
+	if err = interceptor(ctx, "/test.Service/Sign", profileRequest("Default"), nil, nil, success); err != nil {
+		t.Fatalf("expected success for independent profile, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_ExcludedMethods(t *testing.T) {
+	cfg := CircuitConfig{
+		Name:                "test",
+		MaxRequests:         1,
+		Interval:            "30s",
+		Timeout:             "1m",
+		ConsecutiveFailures: 1,
+		FailureStatusCodes:  []codes.Code{14},
+	}
+
+	interceptor, err := CircuitBreaker(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := context.Background()
+
+	fail :=
+		func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return status.Error(codes.Unavailable, "failure")
+		}
+
+	// health service is excluded by default, so failures never open the circuit.
+	for range 3 {
+		err = interceptor(ctx, "/grpc.health.v1.Health/Check", nil, nil, nil, fail)
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected unavailable error, got %v", err)
+		}
+	}
+}
+
+func TestCircuitBreaker_Logger(t *testing.T) {
+	cfg := CircuitConfig{
+		Name:                "test",
+		MaxRequests:         1,
+		Interval:            "30s",
+		Timeout:             "1m",
+		ConsecutiveFailures: 1,
+		FailureStatusCodes:  []codes.Code{14},
+	}
+
+	var buf bytes.Buffer
+	interceptor, err := CircuitBreaker(cfg, WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fail :=
+		func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return status.Error(codes.Unavailable, "failure")
+		}
+
+	_ = interceptor(context.Background(), "/test.Service/Sign", nil, nil, nil, fail)
+
+	if !strings.Contains(buf.String(), "circuit breaker state changed") || !strings.Contains(buf.String(), "to=open") {
+		t.Fatalf("expected state change to be logged by custom logger, got %q", buf.String())
+	}
+}
@@ -0,0 +1,154 @@
+package interceptor
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+)
+
+// metadataField is the name of request and response fields carrying protobuf.Metadata.
+const metadataField = "metadata"
+
+type CoalescingConfig struct {
+	// Methods lists full gRPC method names whose identical concurrent calls share a single call,
+	// e.g. "/CryptoBroker.CryptoGrpc/HashData". Calls are identical when their requests are equal
+	// apart from Metadata, e.g. hash requests of the same profile, output format and input.
+	Methods []string `yaml:"methods"`
+}
+
+// coalescedCall is a call in flight shared by identical calls.
+type coalescedCall struct {
+	done  chan struct{}
+	reply proto.Message
+	err   error
+
+	// abandoned is set when the call failed only because its caller gave up on it
+	abandoned bool
+}
+
+// Create and return interceptor coalescing identical concurrent calls into one.
+// Every caller gets a copy of the shared reply, echoing Metadata of its own request.
+func Coalescing(config CoalescingConfig) grpc.UnaryClientInterceptor {
+	var mu sync.Mutex
+	calls := make(map[string]*coalescedCall)
+
+	return func(
+		ctx context.Context,
+		method string,
+		req any,
+		reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		msg, ok := reply.(proto.Message)
+		r, isProto := req.(proto.Message)
+		if !ok || !isProto || !slices.Contains(config.Methods, method) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		key, err := contentKey(method, r)
+		if err != nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		for {
+			mu.Lock()
+			call, found := calls[key]
+			if !found {
+				call = &coalescedCall{done: make(chan struct{})}
+				calls[key] = call
+			}
+			mu.Unlock()
+
+			if !found {
+				call.err = invoker(ctx, method, req, reply, cc, opts...)
+				call.abandoned = call.err != nil && ctx.Err() != nil
+				if call.err == nil {
+					call.reply = proto.Clone(msg)
+				}
+
+				mu.Lock()
+				delete(calls, key)
+				mu.Unlock()
+				close(call.done)
+
+				return call.err
+			}
+
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return status.FromContextError(ctx.Err()).Err()
+			}
+
+			// calls cancelled by their caller do not fail the others, which try on their own
+			if call.abandoned {
+				continue
+			}
+			if call.err != nil {
+				return call.err
+			}
+
+			proto.Reset(msg)
+			proto.Merge(msg, call.reply)
+			echoMetadata(r, msg)
+
+			return nil
+		}
+	}
+}
+
+// contentKey returns key of method and req, which is a digest of the request without its metadata,
+// so that requests differing only in their metadata get the same key.
+func contentKey(method string, req proto.Message) (string, error) {
+	content := req.ProtoReflect().New()
+	req.ProtoReflect().Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		if field.Name() != metadataField {
+			content.Set(field, value)
+		}
+
+		return true
+	})
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(content.Interface())
+	if err != nil {
+		return "", fmt.Errorf("marshal request: %w", err)
+	}
+
+	digest := sha256.Sum256(data)
+
+	return method + "\x00" + string(digest[:]), nil
+}
+
+// echoMetadata sets Metadata of reply to copy of Metadata of req, as the broker echoes it.
+func echoMetadata(req, reply proto.Message) {
+	if r, ok := req.(interface{ GetMetadata() *protobuf.Metadata }); ok {
+		setMetadata(reply, r.GetMetadata())
+	}
+}
+
+// setMetadata sets Metadata of reply to copy of m, or clears it if m is nil.
+func setMetadata(reply proto.Message, m *protobuf.Metadata) {
+	field := reply.ProtoReflect().Descriptor().Fields().ByName(metadataField)
+	if field == nil || field.Message() == nil ||
+		field.Message().FullName() != (&protobuf.Metadata{}).ProtoReflect().Descriptor().FullName() {
+		return
+	}
+
+	if m != nil {
+		reply.ProtoReflect().Set(field, protoreflect.ValueOfMessage(proto.Clone(m).ProtoReflect()))
+		return
+	}
+
+	reply.ProtoReflect().Clear(field)
+}
@@ -0,0 +1,159 @@
+package interceptor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+)
+
+func hashRequest(id, profile, input string) *protobuf.HashDataRequest {
+	return &protobuf.HashDataRequest{Profile: profile, Input: []byte(input), Metadata: &protobuf.Metadata{Id: id}}
+}
+
+// hashingInvoker answers with the input as hash once released, echoing metadata of the request.
+func hashingInvoker(b *blockingInvoker) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		_ = b.invoke(ctx, method, req, reply, cc, opts...)
+
+		r := req.(*protobuf.HashDataRequest)
+		resp := reply.(*protobuf.HashDataResponse)
+		resp.HashValue = &protobuf.HashDataResponse_HashValueHex{HashValueHex: string(r.GetInput())}
+		resp.Metadata = r.GetMetadata()
+
+		return nil
+	}
+}
+
+func TestCoalescing_IdenticalCalls(t *testing.T) {
+	interceptor := Coalescing(CoalescingConfig{Methods: []string{"/svc/Hash"}})
+
+	blocking := newBlockingInvoker()
+	invoker := hashingInvoker(blocking)
+
+	var wg sync.WaitGroup
+	call := func(id string) {
+		wg.Go(func() {
+			reply := &protobuf.HashDataResponse{}
+			if err := interceptor(context.Background(), "/svc/Hash", hashRequest(id, "Default", "blob"), reply, nil, invoker); err != nil {
+				t.Errorf("interceptor() error = %v", err)
+			}
+
+			// every caller gets the shared result with its own metadata echo
+			if reply.GetHashValueHex() != "blob" || reply.GetMetadata().GetId() != id {
+				t.Errorf("reply = %v, want hash of blob echoing id %q", reply, id)
+			}
+		})
+	}
+
+	call("1")
+	blocking.waitStarted(t)
+	for _, id := range []string{"2", "3", "4"} {
+		call(id)
+	}
+	blocking.assertNotStarted(t)
+
+	close(blocking.release)
+	wg.Wait()
+
+	// calls after the shared one finished are sent again
+	call("5")
+	blocking.waitStarted(t)
+	wg.Wait()
+}
+
+func TestCoalescing_DistinctCalls(t *testing.T) {
+	interceptor := Coalescing(CoalescingConfig{Methods: []string{"/svc/Hash"}})
+
+	blocking := newBlockingInvoker()
+	defer close(blocking.release)
+	invoker := hashingInvoker(blocking)
+
+	requests := []*protobuf.HashDataRequest{
+		hashRequest("1", "Default", "blob"),
+		hashRequest("2", "Default", "other blob"),
+		hashRequest("3", "HSM", "blob"),
+		{Profile: "Default", Input: []byte("blob"), OutputFormat: protobuf.HashOutputFormat_RAW},
+	}
+	for _, req := range requests {
+		go func() {
+			_ = interceptor(context.Background(), "/svc/Hash", req, &protobuf.HashDataResponse{}, nil, invoker)
+		}()
+		blocking.waitStarted(t)
+	}
+
+	// other methods are not coalesced
+	go func() {
+		_ = interceptor(context.Background(), "/svc/Other", requests[0], &protobuf.HashDataResponse{}, nil, invoker)
+	}()
+	blocking.waitStarted(t)
+}
+
+func TestCoalescing_Failures(t *testing.T) {
+	interceptor := Coalescing(CoalescingConfig{Methods: []string{"/svc/Hash"}})
+
+	var calls atomic.Int32
+	started := make(chan struct{}, 10)
+	release := make(chan error)
+	invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls.Add(1)
+		started <- struct{}{}
+
+		select {
+		case err := <-release:
+			return err
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	// failures are shared by all callers
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			errs <- interceptor(context.Background(), "/svc/Hash", hashRequest("1", "Default", "blob"), &protobuf.HashDataResponse{}, nil, invoker)
+		}()
+	}
+	<-started
+	time.Sleep(50 * time.Millisecond)
+	release <- status.Error(codes.Unavailable, "down")
+
+	for range 2 {
+		if err := <-errs; status.Code(err) != codes.Unavailable {
+			t.Errorf("interceptor() error = %v, want code %v", err, codes.Unavailable)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("calls = %d, want 1", calls.Load())
+	}
+
+	// call cancelled by its caller does not fail the others
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		errs <- interceptor(ctx, "/svc/Hash", hashRequest("1", "Default", "blob"), &protobuf.HashDataResponse{}, nil, invoker)
+	}()
+	<-started
+	go func() {
+		errs <- interceptor(context.Background(), "/svc/Hash", hashRequest("2", "Default", "blob"), &protobuf.HashDataResponse{}, nil, invoker)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	<-started
+	release <- nil
+
+	results := map[codes.Code]int{}
+	for range 2 {
+		results[status.Code(<-errs)]++
+	}
+	if results[codes.Canceled] != 1 || results[codes.OK] != 1 {
+		t.Errorf("results = %v, want one cancelled and one successful call", results)
+	}
+}
@@ -0,0 +1,345 @@
+package interceptor
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrOverloaded is returned when a call can not get a slot of the concurrency limiter,
+// because the queue is full or the call waited in it for too long.
+var ErrOverloaded = errors.New("too many concurrent calls")
+
+// Supported adaptive limit algorithms.
+const (
+	// AdaptiveAIMD increases the limit by one while calls succeed and the limit is in use,
+	// and decreases it multiplicatively when the broker signals overload.
+	AdaptiveAIMD = "aimd"
+
+	// AdaptiveGradient follows the ratio of long-term to recent latency of calls, so that the limit
+	// decreases as soon as calls slow down, before the broker signals overload.
+	AdaptiveGradient = "gradient"
+)
+
+// adaptive limits are decreased by this factor on overload
+const backoffRatio = 0.9
+
+type ConcurrencyConfig struct {
+	// MaxConcurrent limits calls in flight, zero means unlimited.
+	MaxConcurrent uint `yaml:"maxConcurrent"`
+
+	// (Optional) MaxQueue limits calls waiting for a slot, further calls fail with ErrOverloaded immediately.
+	// Calls are not queued when zero.
+	MaxQueue uint `yaml:"maxQueue"`
+
+	// (Optional) QueueTimeout bounds waiting for a slot, e.g. "100ms". Calls wait until their context is done when empty.
+	QueueTimeout string `yaml:"queueTimeout"`
+
+	// (Optional) Adaptive adjusts the limit between MinConcurrent and MaxConcurrent, AdaptiveAIMD or AdaptiveGradient.
+	// The limit starts at MaxConcurrent.
+	Adaptive string `yaml:"adaptive"`
+
+	// (Optional) MinConcurrent is the lowest adaptive limit, 1 if zero.
+	MinConcurrent uint `yaml:"minConcurrent"`
+
+	// Methods overrides the settings above for full gRPC method names,
+	// e.g. "/CryptoBroker.CryptoGrpc/SignCertificate". Zero-valued fields are inherited.
+	Methods map[string]ConcurrencyConfig `yaml:"methods"`
+
+	// Profiles overrides the settings for requests using the given crypto broker profile.
+	// Profile overrides are applied on top of method overrides and get a limiter of their own.
+	Profiles map[string]ConcurrencyConfig `yaml:"profiles"`
+}
+
+// merge returns copy of c with all non-zero settings of override applied.
+func (c ConcurrencyConfig) merge(override ConcurrencyConfig) ConcurrencyConfig {
+	if override.MaxConcurrent != 0 {
+		c.MaxConcurrent = override.MaxConcurrent
+	}
+	if override.MaxQueue != 0 {
+		c.MaxQueue = override.MaxQueue
+	}
+	if override.QueueTimeout != "" {
+		c.QueueTimeout = override.QueueTimeout
+	}
+	if override.Adaptive != "" {
+		c.Adaptive = override.Adaptive
+	}
+	if override.MinConcurrent != 0 {
+		c.MinConcurrent = override.MinConcurrent
+	}
+
+	return c
+}
+
+// limiterSettings are resolved settings of limiters for a method and profile.
+type limiterSettings struct {
+	max, min     float64
+	maxQueue     int
+	queueTimeout time.Duration
+	adaptive     string
+}
+
+// concurrencyLimiters holds lazily created limiters, one per method and profile with overrides.
+type concurrencyLimiters struct {
+	config   ConcurrencyConfig
+	settings map[policyKey]limiterSettings
+
+	mu       sync.Mutex
+	limiters map[policyKey]*limiter
+}
+
+// Create and return concurrency limiter interceptor.
+// Every method gets limiter of its own, so that calls of one method can not starve the others.
+func ConcurrencyLimit(config ConcurrencyConfig) (grpc.UnaryClientInterceptor, error) {
+	cls := &concurrencyLimiters{
+		config:   config,
+		settings: make(map[policyKey]limiterSettings),
+		limiters: make(map[policyKey]*limiter),
+	}
+
+	for _, key := range policyKeys(config.Methods, config.Profiles) {
+		resolved := config
+		if override, ok := config.Methods[key.method]; ok {
+			resolved = resolved.merge(override)
+		}
+		if override, ok := config.Profiles[key.profile]; ok {
+			resolved = resolved.merge(override)
+		}
+
+		settings, err := newLimiterSettings(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+
+		cls.settings[key] = settings
+	}
+
+	interceptor := func(
+		ctx context.Context,
+		method string,
+		req any,
+		reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		l := cls.get(method, profileOf(req))
+		if l == nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		if err := l.acquire(ctx); err != nil {
+			return err
+		}
+
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		l.release(time.Since(start), err)
+
+		return err
+	}
+
+	return interceptor, nil
+}
+
+// get returns limiter of given method and profile, creating it on first use.
+// It returns nil if calls are not limited.
+func (cls *concurrencyLimiters) get(method, profile string) *limiter {
+	settingsKey := lookupKey(cls.config.Methods, cls.config.Profiles, method, profile)
+
+	settings := cls.settings[settingsKey]
+	if settings.max == 0 {
+		return nil
+	}
+
+	stateKey := policyKey{method: method, profile: settingsKey.profile}
+
+	cls.mu.Lock()
+	defer cls.mu.Unlock()
+
+	l, ok := cls.limiters[stateKey]
+	if !ok {
+		l = newLimiter(settings)
+		cls.limiters[stateKey] = l
+	}
+
+	return l
+}
+
+func newLimiterSettings(config ConcurrencyConfig) (limiterSettings, error) {
+	settings := limiterSettings{
+		max:      float64(config.MaxConcurrent),
+		min:      float64(max(config.MinConcurrent, 1)),
+		maxQueue: int(config.MaxQueue),
+		adaptive: config.Adaptive,
+	}
+
+	if config.QueueTimeout != "" {
+		timeout, err := time.ParseDuration(config.QueueTimeout)
+		if err != nil {
+			return limiterSettings{}, fmt.Errorf("parse queue timeout: %w", err)
+		}
+
+		settings.queueTimeout = timeout
+	}
+
+	switch config.Adaptive {
+	case "", AdaptiveAIMD, AdaptiveGradient:
+	default:
+		return limiterSettings{}, fmt.Errorf("unknown adaptive limit %q", config.Adaptive)
+	}
+
+	settings.min = min(settings.min, settings.max)
+
+	return settings, nil
+}
+
+// limiter is a semaphore with FIFO queue and limit adjustable by the observed calls.
+type limiter struct {
+	settings limiterSettings
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	queue    *list.List // of chan struct{}, closed when the slot is granted
+
+	// latency averages of gradient limit in seconds
+	longLatency, shortLatency float64
+}
+
+func newLimiter(settings limiterSettings) *limiter {
+	return &limiter{settings: settings, limit: settings.max, queue: list.New()}
+}
+
+// acquire waits for a slot in FIFO order. It fails with ErrOverloaded if the queue is full or
+// the queue timeout elapses, and with status of ctx error if ctx is done first.
+func (l *limiter) acquire(ctx context.Context) error {
+	l.mu.Lock()
+	if l.inflight < l.slots() && l.queue.Len() == 0 {
+		l.inflight++
+		l.mu.Unlock()
+
+		return nil
+	}
+
+	if l.queue.Len() >= l.settings.maxQueue {
+		inflight := l.inflight
+		l.mu.Unlock()
+
+		return fmt.Errorf("%w: %d calls in flight, queue is full", ErrOverloaded, inflight)
+	}
+
+	granted := make(chan struct{})
+	element := l.queue.PushBack(granted)
+	l.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if l.settings.queueTimeout > 0 {
+		timer := time.NewTimer(l.settings.queueTimeout)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-granted:
+		return nil
+	case <-timeout:
+		err = fmt.Errorf("%w: no slot within queue timeout of %v", ErrOverloaded, l.settings.queueTimeout)
+	case <-ctx.Done():
+		err = status.FromContextError(ctx.Err()).Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-granted:
+		// slot was granted meanwhile, pass it on
+		l.inflight--
+		l.grant()
+	default:
+		l.queue.Remove(element)
+	}
+
+	return err
+}
+
+// release frees slot of call that took latency and failed with err, if any.
+func (l *limiter) release(latency time.Duration, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.adapt(latency, err)
+	l.inflight--
+	l.grant()
+}
+
+// grant hands free slots to queued calls.
+func (l *limiter) grant() {
+	for l.inflight < l.slots() && l.queue.Len() > 0 {
+		granted := l.queue.Remove(l.queue.Front()).(chan struct{})
+		l.inflight++
+		close(granted)
+	}
+}
+
+// slots returns the current limit as number of calls.
+func (l *limiter) slots() int {
+	return int(l.limit)
+}
+
+// adapt adjusts adaptive limit after call that took latency and failed with err, if any.
+func (l *limiter) adapt(latency time.Duration, err error) {
+	overloaded := false
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.ResourceExhausted, codes.DeadlineExceeded:
+		overloaded = true
+	default:
+		// other failures, e.g. of a broker failing fast or calls cancelled by caller, tell nothing about its capacity
+		return
+	}
+
+	switch l.settings.adaptive {
+	case AdaptiveAIMD:
+		if overloaded {
+			l.limit *= backoffRatio
+		} else if 2*l.inflight >= l.slots() {
+			// increase only while the limit is actually in use
+			l.limit++
+		}
+	case AdaptiveGradient:
+		sample := latency.Seconds()
+		if l.longLatency == 0 {
+			l.longLatency, l.shortLatency = sample, sample
+		}
+
+		// the long-term average follows slowly, so that it represents latency of a healthy broker
+		l.shortLatency = 0.5*l.shortLatency + 0.5*sample
+		l.longLatency = 0.95*l.longLatency + 0.05*sample
+
+		if overloaded {
+			l.limit *= backoffRatio
+			break
+		}
+
+		gradient := max(0.5, min(1.0, l.longLatency/l.shortLatency))
+		headroom := math.Sqrt(l.limit)
+		l.limit = 0.8*l.limit + 0.2*(gradient*l.limit+headroom)
+	default:
+		return
+	}
+
+	l.limit = max(l.settings.min, min(l.settings.max, l.limit))
+}
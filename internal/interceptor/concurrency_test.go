@@ -0,0 +1,278 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// blockingInvoker blocks calls until they are released, reporting every started call.
+type blockingInvoker struct {
+	started chan any
+	release chan struct{}
+}
+
+func newBlockingInvoker() *blockingInvoker {
+	return &blockingInvoker{started: make(chan any, 100), release: make(chan struct{})}
+}
+
+func (b *blockingInvoker) invoke(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	b.started <- req
+	<-b.release
+
+	return nil
+}
+
+// waitStarted returns request of the next started call.
+func (b *blockingInvoker) waitStarted(t *testing.T) any {
+	t.Helper()
+
+	select {
+	case req := <-b.started:
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatal("call did not start")
+		return nil
+	}
+}
+
+func (b *blockingInvoker) assertNotStarted(t *testing.T) {
+	t.Helper()
+
+	select {
+	case req := <-b.started:
+		t.Fatalf("call %v started over the limit", req)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestConcurrencyLimit_Queue(t *testing.T) {
+	interceptor, err := ConcurrencyLimit(ConcurrencyConfig{MaxConcurrent: 1, MaxQueue: 2})
+	if err != nil {
+		t.Fatalf("ConcurrencyLimit() error = %v", err)
+	}
+
+	ctx := context.Background()
+	invoker := newBlockingInvoker()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	call := func(req string) {
+		wg.Go(func() { errs <- interceptor(ctx, "/svc/Method", profileRequest(req), nil, nil, invoker.invoke) })
+	}
+
+	call("first")
+	invoker.waitStarted(t)
+
+	// queued calls are started in order of arrival once slots are freed
+	call("second")
+	invoker.assertNotStarted(t)
+	call("third")
+	invoker.assertNotStarted(t)
+
+	// queue is full
+	if err := interceptor(ctx, "/svc/Method", profileRequest("fourth"), nil, nil, invoker.invoke); !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("interceptor() error = %v over full queue, want %v", err, ErrOverloaded)
+	}
+
+	for _, want := range []string{"second", "third"} {
+		invoker.release <- struct{}{}
+		if got := invoker.waitStarted(t); got != profileRequest(want) {
+			t.Fatalf("started %v, want %v", got, want)
+		}
+	}
+	invoker.release <- struct{}{}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("interceptor() error = %v", err)
+		}
+	}
+}
+
+func TestConcurrencyLimit_QueueTimeoutAndCancellation(t *testing.T) {
+	interceptor, err := ConcurrencyLimit(ConcurrencyConfig{MaxConcurrent: 1, MaxQueue: 5, QueueTimeout: "20ms"})
+	if err != nil {
+		t.Fatalf("ConcurrencyLimit() error = %v", err)
+	}
+
+	invoker := newBlockingInvoker()
+	defer close(invoker.release)
+
+	go func() { _ = interceptor(context.Background(), "/svc/Method", nil, nil, nil, invoker.invoke) }()
+	invoker.waitStarted(t)
+
+	start := time.Now()
+	if err := interceptor(context.Background(), "/svc/Method", nil, nil, nil, invoker.invoke); !errors.Is(err, ErrOverloaded) {
+		t.Errorf("interceptor() error = %v after queue timeout, want %v", err, ErrOverloaded)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("interceptor() waited %v, want at least queue timeout", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := interceptor(ctx, "/svc/Method", nil, nil, nil, invoker.invoke); status.Code(err) != codes.Canceled {
+		t.Errorf("interceptor() error = %v for cancelled call, want code %v", err, codes.Canceled)
+	}
+
+	invoker.assertNotStarted(t)
+}
+
+func TestConcurrencyLimit_Bulkheads(t *testing.T) {
+	interceptor, err := ConcurrencyLimit(ConcurrencyConfig{
+		MaxConcurrent: 10,
+		Methods: map[string]ConcurrencyConfig{
+			"/svc/Sign": {MaxConcurrent: 1},
+		},
+		Profiles: map[string]ConcurrencyConfig{
+			"HSM": {MaxConcurrent: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ConcurrencyLimit() error = %v", err)
+	}
+
+	invoker := newBlockingInvoker()
+	defer close(invoker.release)
+
+	ctx := context.Background()
+
+	// saturate signing and the HSM profile
+	go func() {
+		_ = interceptor(context.Background(), "/svc/Sign", profileRequest("Default"), nil, nil, invoker.invoke)
+	}()
+	invoker.waitStarted(t)
+	go func() {
+		_ = interceptor(context.Background(), "/svc/Hash", profileRequest("HSM"), nil, nil, invoker.invoke)
+	}()
+	invoker.waitStarted(t)
+
+	// calls are not queued without MaxQueue
+	if err := interceptor(ctx, "/svc/Sign", profileRequest("Default"), nil, nil, invoker.invoke); !errors.Is(err, ErrOverloaded) {
+		t.Errorf("interceptor() error = %v over limit of method, want %v", err, ErrOverloaded)
+	}
+	if err := interceptor(ctx, "/svc/Hash", profileRequest("HSM"), nil, nil, invoker.invoke); !errors.Is(err, ErrOverloaded) {
+		t.Errorf("interceptor() error = %v over limit of profile, want %v", err, ErrOverloaded)
+	}
+
+	// other methods and profiles are not affected
+	for range 3 {
+		go func() {
+			_ = interceptor(context.Background(), "/svc/Hash", profileRequest("Default"), nil, nil, invoker.invoke)
+		}()
+		invoker.waitStarted(t)
+	}
+}
+
+func TestConcurrencyLimit_Unlimited(t *testing.T) {
+	interceptor, err := ConcurrencyLimit(ConcurrencyConfig{})
+	if err != nil {
+		t.Fatalf("ConcurrencyLimit() error = %v", err)
+	}
+
+	invoker := newBlockingInvoker()
+	defer close(invoker.release)
+
+	for range 50 {
+		go func() { _ = interceptor(context.Background(), "/svc/Method", nil, nil, nil, invoker.invoke) }()
+		invoker.waitStarted(t)
+	}
+}
+
+func TestLimiter_AIMD(t *testing.T) {
+	l := newLimiter(limiterSettings{max: 10, min: 2, adaptive: AdaptiveAIMD})
+
+	overload := status.Error(codes.ResourceExhausted, "busy")
+	for range 20 {
+		l.inflight = 1
+		l.release(time.Millisecond, overload)
+	}
+	if l.limit != 2 {
+		t.Fatalf("limit = %v after overload, want minimum 2", l.limit)
+	}
+
+	// the limit grows only while it is in use
+	l.inflight = 1
+	l.release(time.Millisecond, nil)
+	if l.limit != 3 {
+		t.Fatalf("limit = %v after success in use, want 3", l.limit)
+	}
+	l.inflight = 1
+	l.release(time.Millisecond, nil)
+	if l.limit != 3 {
+		t.Errorf("limit = %v after success below half of limit, want 3", l.limit)
+	}
+
+	// cancellations tell nothing about the broker
+	l.inflight = 1
+	l.release(time.Millisecond, status.Error(codes.Canceled, "gone"))
+	if l.limit != 3 {
+		t.Errorf("limit = %v after cancellation, want 3", l.limit)
+	}
+
+	// a broker failing fast does not make the limit grow
+	for range 10 {
+		l.inflight = 2
+		l.release(time.Millisecond, status.Error(codes.Unavailable, "down"))
+	}
+	if l.limit != 3 {
+		t.Errorf("limit = %v after burst of unavailable errors, want 3", l.limit)
+	}
+}
+
+func TestLimiter_Gradient(t *testing.T) {
+	l := newLimiter(limiterSettings{max: 100, min: 1, adaptive: AdaptiveGradient})
+
+	for range 50 {
+		l.inflight = 1
+		l.release(10*time.Millisecond, nil)
+	}
+	if l.limit != 100 {
+		t.Fatalf("limit = %v at steady latency, want maximum 100", l.limit)
+	}
+
+	// calls slowing down decrease the limit before the broker signals overload
+	for range 10 {
+		l.inflight = 1
+		l.release(50*time.Millisecond, nil)
+	}
+	if l.limit >= 80 {
+		t.Errorf("limit = %v after latency increased, want decreased", l.limit)
+	}
+
+	// fast failures are no latency samples, which would raise the limit again
+	limit := l.limit
+	for range 10 {
+		l.inflight = 1
+		l.release(time.Millisecond, status.Error(codes.Unavailable, "down"))
+	}
+	if l.limit != limit {
+		t.Errorf("limit = %v after burst of unavailable errors, want %v", l.limit, limit)
+	}
+}
+
+func TestConcurrencyLimit_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ConcurrencyConfig
+	}{
+		{name: "unknown adaptive limit", config: ConcurrencyConfig{MaxConcurrent: 1, Adaptive: "vegas"}},
+		{name: "invalid queue timeout of method", config: ConcurrencyConfig{Methods: map[string]ConcurrencyConfig{"/svc/Method": {QueueTimeout: "soon"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ConcurrencyLimit(tt.config); err == nil {
+				t.Error("ConcurrencyLimit() error = nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package interceptor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/metrics"
+)
+
+const (
+	// hedgeWindow is the number of latest latencies kept per method for percentile delays
+	hedgeWindow = 256
+
+	// hedgeMinSamples is the number of latencies observed before percentile delay replaces the fixed one
+	hedgeMinSamples = 20
+)
+
+type HedgingConfig struct {
+	// Methods lists full gRPC method names whose calls are hedged, e.g. "/CryptoBroker.CryptoGrpc/HashData".
+	// Calls of methods that are not idempotent, see RetryConfig.Idempotent, are only hedged
+	// when they carry IdempotencyKeyHeader metadata.
+	Methods []string `yaml:"methods"`
+
+	// Delay after which another attempt is sent while the previous ones did not answer, e.g. "50ms".
+	// With Percentile it is used until enough latencies of the method were observed.
+	Delay string `yaml:"delay"`
+
+	// (Optional) Percentile of observed latency of the method used as delay instead, e.g. 95.
+	Percentile float64 `yaml:"percentile"`
+
+	// (Optional) MaxAttempts sent per call including the first one, 2 if zero.
+	MaxAttempts uint `yaml:"maxAttempts"`
+}
+
+// hedgingSettings are resolved settings of the hedging interceptor.
+type hedgingSettings struct {
+	delay       time.Duration
+	percentile  float64
+	maxAttempts int
+
+	// resendable tells per method and profile whether calls can be sent again without idempotency key
+	resendable map[policyKey]bool
+}
+
+// Create and return hedging interceptor. Calls not answered within the delay are sent again,
+// the first successful answer is returned and the other attempts are cancelled.
+// Idempotency of methods is taken from retry config, hedges sent are counted in metrics given with WithMetrics.
+func Hedging(config HedgingConfig, retry RetryConfig, opts ...Option) (grpc.UnaryClientInterceptor, error) {
+	o := newOptions(opts)
+
+	settings, err := newHedgingSettings(config, retry)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	latencies := make(map[string]*latencyWindow)
+
+	return func(
+		ctx context.Context,
+		method string,
+		req any,
+		reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		profile := profileOf(req)
+		msg, ok := reply.(proto.Message)
+		if !ok || !slices.Contains(config.Methods, method) ||
+			!settings.resendable[lookupKey(retry.Methods, retry.Profiles, method, profile)] && idempotencyKey(ctx) == "" {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		mu.Lock()
+		window, ok := latencies[method]
+		if !ok {
+			window = &latencyWindow{}
+			latencies[method] = window
+		}
+		mu.Unlock()
+
+		delay := settings.delay
+		if settings.percentile > 0 {
+			if observed, ok := window.percentile(settings.percentile); ok {
+				delay = observed
+			}
+		}
+
+		hedge := func() {
+			o.measure().Add(metrics.Hedges, 1,
+				metrics.Label{Key: metrics.LabelMethod, Value: method},
+				metrics.Label{Key: metrics.LabelProfile, Value: profile},
+			)
+		}
+
+		winner, err := hedgeCall(ctx, delay, settings.maxAttempts, msg, opts, hedge,
+			func(ctx context.Context, reply proto.Message, opts []grpc.CallOption) error {
+				return invoker(ctx, method, req, reply, cc, opts...)
+			})
+
+		// trailers of failed call carry e.g. server pushback for the retry interceptor
+		winner.commit()
+		if err != nil {
+			return err
+		}
+
+		window.observe(winner.latency)
+
+		proto.Reset(msg)
+		proto.Merge(msg, winner.reply)
+
+		return nil
+	}, nil
+}
+
+func newHedgingSettings(config HedgingConfig, retry RetryConfig) (hedgingSettings, error) {
+	settings := hedgingSettings{
+		percentile:  config.Percentile,
+		maxAttempts: int(max(config.MaxAttempts, 2)),
+		resendable:  make(map[policyKey]bool),
+	}
+
+	if len(config.Methods) == 0 {
+		return settings, nil
+	}
+
+	delay, err := time.ParseDuration(config.Delay)
+	if err != nil {
+		return hedgingSettings{}, fmt.Errorf("parse hedging delay: %w", err)
+	}
+	if delay <= 0 {
+		return hedgingSettings{}, fmt.Errorf("hedging delay must be positive, got %v", delay)
+	}
+	settings.delay = delay
+
+	if config.Percentile < 0 || config.Percentile >= 100 {
+		return hedgingSettings{}, fmt.Errorf("hedging percentile must be between 0 and 100, got %v", config.Percentile)
+	}
+
+	for _, key := range policyKeys(retry.Methods, retry.Profiles) {
+		resolved := retry
+		if override, ok := retry.Methods[key.method]; ok {
+			resolved = resolved.merge(override)
+		}
+		if override, ok := retry.Profiles[key.profile]; ok {
+			resolved = resolved.merge(override)
+		}
+
+		settings.resendable[key] = resolved.Idempotent == nil || *resolved.Idempotent
+	}
+
+	return settings, nil
+}
+
+// attemptResult is outcome of a single attempt of hedged call.
+type attemptResult struct {
+	reply   proto.Message
+	latency time.Duration
+	err     error
+
+	// commit hands headers, trailers and peer received by the attempt over to the caller
+	commit func()
+}
+
+// hedgeCall sends up to maxAttempts attempts with invoke, starting another one whenever the previous
+// did not answer within delay, and returns reply of the first successful attempt. The remaining
+// attempts are cancelled. Every attempt gets reply and call options of its own, so that losers can not
+// corrupt the winner. If all attempts fail, the last one to fail is returned with its error.
+func hedgeCall(
+	ctx context.Context,
+	delay time.Duration,
+	maxAttempts int,
+	reply proto.Message,
+	opts []grpc.CallOption,
+	hedge func(),
+	invoke func(context.Context, proto.Message, []grpc.CallOption) error,
+) (attemptResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan attemptResult, maxAttempts)
+	send := func() {
+		attemptReply := reply.ProtoReflect().New().Interface()
+		attemptOpts, commit := attemptOptions(opts)
+		go func() {
+			start := time.Now()
+			err := invoke(ctx, attemptReply, attemptOpts)
+			results <- attemptResult{reply: attemptReply, latency: time.Since(start), err: err, commit: commit}
+		}()
+	}
+
+	send()
+	sent, pending := 1, 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var failed attemptResult
+	for pending > 0 {
+		select {
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				return result, nil
+			}
+
+			// rejections of hedges, e.g. by the open circuit breaker, leave other attempts running
+			failed = result
+		case <-timer.C:
+			if sent < maxAttempts && ctx.Err() == nil {
+				hedge()
+				send()
+				sent++
+				pending++
+
+				timer.Reset(delay)
+			}
+		}
+	}
+
+	return failed, failed.err
+}
+
+// attemptOptions returns copy of opts for a single attempt, in which options receiving headers, trailers
+// and peer of the call write to attempt's own variables. They are copied to the variables of opts by commit.
+func attemptOptions(opts []grpc.CallOption) ([]grpc.CallOption, func()) {
+	attemptOpts := slices.Clone(opts)
+
+	var commits []func()
+	for i, opt := range attemptOpts {
+		switch o := opt.(type) {
+		case grpc.HeaderCallOption:
+			header := new(metadata.MD)
+			attemptOpts[i] = grpc.Header(header)
+			commits = append(commits, func() { *o.HeaderAddr = *header })
+		case grpc.TrailerCallOption:
+			trailer := new(metadata.MD)
+			attemptOpts[i] = grpc.Trailer(trailer)
+			commits = append(commits, func() { *o.TrailerAddr = *trailer })
+		case grpc.PeerCallOption:
+			p := new(peer.Peer)
+			attemptOpts[i] = grpc.Peer(p)
+			commits = append(commits, func() { *o.PeerAddr = *p })
+		}
+	}
+
+	return attemptOpts, func() {
+		for _, commit := range commits {
+			commit()
+		}
+	}
+}
+
+// latencyWindow keeps the latest latencies of successful calls.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (w *latencyWindow) observe(latency time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.samples) < hedgeWindow {
+		w.samples = append(w.samples, latency)
+		return
+	}
+
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % hedgeWindow
+}
+
+// percentile returns p-th percentile of observed latencies, or false until enough of them were observed.
+func (w *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	w.mu.Lock()
+	samples := slices.Clone(w.samples)
+	w.mu.Unlock()
+
+	if len(samples) < hedgeMinSamples {
+		return 0, false
+	}
+
+	slices.Sort(samples)
+	rank := int(math.Ceil(p/100*float64(len(samples)))) - 1
+
+	return samples[max(rank, 0)], true
+}
@@ -0,0 +1,313 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/metrics"
+)
+
+// slowFirstInvoker blocks the first attempt until it is cancelled, later attempts answer with their number
+// in reply and trailer.
+type slowFirstInvoker struct {
+	attempts  atomic.Int32
+	cancelled chan struct{}
+}
+
+func newSlowFirstInvoker() *slowFirstInvoker {
+	return &slowFirstInvoker{cancelled: make(chan struct{})}
+}
+
+func (s *slowFirstInvoker) invoke(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	n := s.attempts.Add(1)
+	if n == 1 {
+		select {
+		case <-ctx.Done():
+			close(s.cancelled)
+			return status.FromContextError(ctx.Err()).Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	}
+
+	reply.(*wrapperspb.Int32Value).Value = n
+	for _, opt := range opts {
+		if trailer, ok := opt.(grpc.TrailerCallOption); ok {
+			*trailer.TrailerAddr = metadata.Pairs("attempt", strconv.Itoa(int(n)))
+		}
+	}
+
+	return nil
+}
+
+func TestHedging_FirstResponseWins(t *testing.T) {
+	m := metrics.NewMemory()
+	interceptor, err := Hedging(HedgingConfig{Methods: []string{"/svc/Hash"}, Delay: "10ms"}, RetryConfig{}, WithMetrics(m))
+	if err != nil {
+		t.Fatalf("Hedging() error = %v", err)
+	}
+
+	invoker := newSlowFirstInvoker()
+	reply := &wrapperspb.Int32Value{}
+	var trailer metadata.MD
+	if err := interceptor(context.Background(), "/svc/Hash", profileRequest("HSM"), reply, nil, invoker.invoke, grpc.Trailer(&trailer)); err != nil {
+		t.Fatalf("interceptor() error = %v", err)
+	}
+
+	if reply.GetValue() != 2 || !slices.Equal(trailer.Get("attempt"), []string{"2"}) {
+		t.Errorf("reply = %d with trailer %v, want answer of the hedge 2", reply.GetValue(), trailer)
+	}
+
+	select {
+	case <-invoker.cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow attempt was not cancelled")
+	}
+
+	got := m.Counter(metrics.Hedges,
+		metrics.Label{Key: metrics.LabelMethod, Value: "/svc/Hash"},
+		metrics.Label{Key: metrics.LabelProfile, Value: "HSM"},
+	)
+	if got != 1 {
+		t.Errorf("hedges = %v, want 1", got)
+	}
+}
+
+func TestHedging_FastCallsAreNotHedged(t *testing.T) {
+	interceptor, err := Hedging(HedgingConfig{Methods: []string{"/svc/Hash"}, Delay: "1s", MaxAttempts: 3}, RetryConfig{})
+	if err != nil {
+		t.Fatalf("Hedging() error = %v", err)
+	}
+
+	calls := 0
+	invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		return status.Error(codes.InvalidArgument, "bad input")
+	}
+
+	if err := interceptor(context.Background(), "/svc/Hash", nil, &wrapperspb.Int32Value{}, nil, invoker); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("interceptor() error = %v, want code %v", err, codes.InvalidArgument)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestHedging_OnlyIdempotentMethods(t *testing.T) {
+	notIdempotent := false
+	retry := RetryConfig{Methods: map[string]RetryConfig{"/svc/Sign": {Idempotent: &notIdempotent}}}
+
+	interceptor, err := Hedging(HedgingConfig{Methods: []string{"/svc/Hash", "/svc/Sign"}, Delay: "10ms"}, retry)
+	if err != nil {
+		t.Fatalf("Hedging() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		method  string
+		attempt int32
+	}{
+		{name: "method not listed", ctx: context.Background(), method: "/svc/Other", attempt: 1},
+		{name: "method not idempotent", ctx: context.Background(), method: "/svc/Sign", attempt: 1},
+		{name: "idempotency key", ctx: metadata.AppendToOutgoingContext(context.Background(), IdempotencyKeyHeader, "123"), method: "/svc/Sign", attempt: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoker := newSlowFirstInvoker()
+
+			// calls that are not hedged are only cancelled with their context
+			ctx, cancel := context.WithTimeout(tt.ctx, 100*time.Millisecond)
+			defer cancel()
+
+			_ = interceptor(ctx, tt.method, nil, &wrapperspb.Int32Value{}, nil, invoker.invoke)
+			if got := invoker.attempts.Load(); got != tt.attempt {
+				t.Errorf("attempts = %d, want %d", got, tt.attempt)
+			}
+		})
+	}
+}
+
+func TestHedging_CircuitBreaker(t *testing.T) {
+	interceptor, err := Hedging(HedgingConfig{Methods: []string{"/svc/Hash"}, Delay: "10ms", MaxAttempts: 3}, RetryConfig{})
+	if err != nil {
+		t.Fatalf("Hedging() error = %v", err)
+	}
+
+	// hedges rejected by the open breaker do not fail the call still waiting for the first attempt
+	var attempts atomic.Int32
+	invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		if attempts.Add(1) > 1 {
+			return ErrCircuitOpen
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		reply.(*wrapperspb.Int32Value).Value = 1
+
+		return nil
+	}
+
+	reply := &wrapperspb.Int32Value{}
+	if err := interceptor(context.Background(), "/svc/Hash", nil, reply, nil, invoker); err != nil {
+		t.Fatalf("interceptor() error = %v", err)
+	}
+	if reply.GetValue() != 1 || attempts.Load() != 3 {
+		t.Errorf("reply = %d after %d attempts, want answer of the first attempt after 3", reply.GetValue(), attempts.Load())
+	}
+
+	// calls rejected right away are not hedged
+	attempts.Store(1)
+	if err := interceptor(context.Background(), "/svc/Hash", nil, reply, nil, invoker); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("interceptor() error = %v, want %v", err, ErrCircuitOpen)
+	}
+	if attempts.Load() != 2 {
+		t.Errorf("attempts = %d, want single one", attempts.Load()-1)
+	}
+}
+
+func TestHedging_CancelledAttemptIsNotSuccess(t *testing.T) {
+	m := metrics.NewMemory()
+	breaker, err := CircuitBreaker(CircuitConfig{
+		Name:                "test",
+		MaxRequests:         2,
+		Interval:            "0s",
+		Timeout:             "20ms",
+		ConsecutiveFailures: 1,
+		FailureStatusCodes:  []codes.Code{codes.Unavailable},
+	}, WithMetrics(m))
+	if err != nil {
+		t.Fatalf("CircuitBreaker() error = %v", err)
+	}
+	hedging, err := Hedging(HedgingConfig{Methods: []string{"/svc/Hash"}, Delay: "10ms"}, RetryConfig{})
+	if err != nil {
+		t.Fatalf("Hedging() error = %v", err)
+	}
+
+	// open the breaker and let it become half-open
+	fail := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.Unavailable, "failure")
+	}
+	_ = breaker(context.Background(), "/svc/Hash", nil, nil, nil, fail)
+	time.Sleep(30 * time.Millisecond)
+
+	// every attempt passes the breaker, the slow primary loses the race to the hedge
+	slow := newSlowFirstInvoker()
+	guarded := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return breaker(ctx, method, req, reply, cc, slow.invoke, opts...)
+	}
+	if err := hedging(context.Background(), "/svc/Hash", nil, &wrapperspb.Int32Value{}, nil, guarded); err != nil {
+		t.Fatalf("interceptor() error = %v", err)
+	}
+	<-slow.cancelled
+	time.Sleep(10 * time.Millisecond)
+
+	// half-open breaker needs 2 successes to close, the cancelled primary must not be one of them
+	name := metrics.Label{Key: metrics.LabelName, Value: "test"}
+	if state, _ := m.Gauge(metrics.CircuitState, name); state != float64(gobreaker.StateHalfOpen) {
+		t.Errorf("circuit state = %v, want half-open %d", state, gobreaker.StateHalfOpen)
+	}
+}
+
+func TestHedging_Pushback(t *testing.T) {
+	// initial backoff is so long that test only finishes in time if pushback is honored
+	retryConfig := RetryConfig{
+		MaxAttempts:          3,
+		InitialBackoff:       "1h",
+		BackoffMultiplier:    1,
+		RetryableStatusCodes: []codes.Code{codes.Unavailable},
+	}
+	hedging, err := Hedging(HedgingConfig{Methods: []string{"/svc/Hash"}, Delay: "1h"}, retryConfig)
+	if err != nil {
+		t.Fatalf("Hedging() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		pushback string
+		want     int32
+	}{
+		{name: "pushback of failed hedged call replaces backoff", pushback: "1", want: 3},
+		{name: "negative pushback of failed hedged call stops retries", pushback: "-1", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retry, err := Retry(retryConfig)
+			if err != nil {
+				t.Fatalf("Retry() error = %v", err)
+			}
+
+			var attempts atomic.Int32
+			invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				attempts.Add(1)
+				setTrailer(opts, metadata.Pairs(PushbackTrailer, tt.pushback))
+				return status.Error(codes.Unavailable, "busy")
+			}
+			hedged := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return hedging(ctx, method, req, reply, cc, invoker, opts...)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			if err := retry(ctx, "/svc/Hash", nil, &wrapperspb.Int32Value{}, nil, hedged); status.Code(err) != codes.Unavailable {
+				t.Fatalf("interceptor() error = %v, want code %v", err, codes.Unavailable)
+			}
+			if attempts.Load() != tt.want {
+				t.Errorf("attempts = %d, want %d", attempts.Load(), tt.want)
+			}
+		})
+	}
+}
+
+func TestLatencyWindow_Percentile(t *testing.T) {
+	var w latencyWindow
+	for i := range hedgeMinSamples - 1 {
+		w.observe(time.Duration(i+1) * time.Millisecond)
+	}
+	if _, ok := w.percentile(95); ok {
+		t.Fatal("percentile() is known before enough latencies were observed")
+	}
+
+	w.observe(20 * time.Millisecond)
+	if got, _ := w.percentile(95); got != 19*time.Millisecond {
+		t.Errorf("percentile(95) = %v, want 19ms", got)
+	}
+
+	// only the latest latencies are kept
+	for range hedgeWindow {
+		w.observe(time.Second)
+	}
+	if got, _ := w.percentile(50); got != time.Second {
+		t.Errorf("percentile(50) = %v, want 1s", got)
+	}
+}
+
+func TestHedging_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config HedgingConfig
+	}{
+		{name: "missing delay", config: HedgingConfig{Methods: []string{"/svc/Hash"}}},
+		{name: "negative delay", config: HedgingConfig{Methods: []string{"/svc/Hash"}, Delay: "-1s"}},
+		{name: "percentile out of range", config: HedgingConfig{Methods: []string{"/svc/Hash"}, Delay: "10ms", Percentile: 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Hedging(tt.config, RetryConfig{}); err == nil {
+				t.Error("Hedging() error = nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package interceptor
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type IdempotencyConfig struct {
+	// Window for which result of successful call is replayed to calls carrying the same idempotency key.
+	Window string `yaml:"window"`
+
+	// Methods lists full gRPC method names whose results are replayed.
+	Methods []string `yaml:"methods"`
+
+	// MaxEntries caps the number of results kept for replay, the oldest results are dropped first.
+	// Zero means 10000.
+	MaxEntries int `yaml:"maxEntries"`
+}
+
+// defaultIdempotencyMaxEntries is the number of results kept for replay unless set in IdempotencyConfig.
+const defaultIdempotencyMaxEntries = 10000
+
+// ErrIdempotencyKeyReused is matched by IdempotencyKeyReusedError.
+var ErrIdempotencyKeyReused = errors.New("idempotency key reused for another request")
+
+// IdempotencyKeyReusedError reports call carrying the idempotency key of an earlier call with another request,
+// which is failed instead of replaying the result of the other request.
+type IdempotencyKeyReusedError struct {
+	Method string
+	Key    string
+}
+
+func (e *IdempotencyKeyReusedError) Error() string {
+	return fmt.Sprintf("%s: idempotency key %q was used for another request", e.Method, e.Key)
+}
+
+func (e *IdempotencyKeyReusedError) Is(target error) bool {
+	return target == ErrIdempotencyKeyReused
+}
+
+// ReplayedCallOption reports whether the result of the call was replayed instead of received from the server.
+type ReplayedCallOption struct {
+	grpc.EmptyCallOption
+
+	Replayed *bool
+}
+
+// Replayed returns call option setting replayed to true if the result of the call is replayed by Idempotency.
+func Replayed(replayed *bool) grpc.CallOption {
+	return ReplayedCallOption{Replayed: replayed}
+}
+
+// idempotencyEntry holds result of a call, or promise of it while the call is in flight.
+type idempotencyEntry struct {
+	key string
+
+	// content is the key of the request apart from its metadata, see contentKey
+	content string
+
+	done    chan struct{}
+	reply   proto.Message
+	err     error
+	expires time.Time
+}
+
+// idempotencyStore holds entries of calls in flight and results of completed calls, which expire in order of completion.
+type idempotencyStore struct {
+	window     time.Duration
+	maxEntries int
+
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	completed *list.List // of *idempotencyEntry, oldest first
+}
+
+// get returns entry of key, or registers new entry with content if there is none.
+func (s *idempotencyStore) get(key, content string) (entry *idempotencyEntry, found bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for front := s.completed.Front(); front != nil && now.After(front.Value.(*idempotencyEntry).expires); front = s.completed.Front() {
+		delete(s.entries, s.completed.Remove(front).(*idempotencyEntry).key)
+	}
+
+	if entry, found = s.entries[key]; !found {
+		entry = &idempotencyEntry{key: key, content: content, done: make(chan struct{})}
+		s.entries[key] = entry
+	}
+
+	return entry, found
+}
+
+// complete sets result of entry, keeping it for replay if successful, and wakes up calls waiting for it.
+func (s *idempotencyStore) complete(entry *idempotencyEntry, reply proto.Message, err error) {
+	s.mu.Lock()
+	if err != nil {
+		delete(s.entries, entry.key)
+	} else {
+		entry.reply = proto.Clone(reply)
+		entry.expires = time.Now().Add(s.window)
+		s.completed.PushBack(entry)
+
+		for s.completed.Len() > s.maxEntries {
+			delete(s.entries, s.completed.Remove(s.completed.Front()).(*idempotencyEntry).key)
+		}
+	}
+	entry.err = err
+	s.mu.Unlock()
+
+	close(entry.done)
+}
+
+// Create and return interceptor replaying results of calls carrying the same idempotency key.
+// Concurrent calls with the same key wait for the first one instead of reaching the server.
+// Calls with the same key but another request fail with IdempotencyKeyReusedError.
+func Idempotency(config IdempotencyConfig) (grpc.UnaryClientInterceptor, error) {
+	window, err := time.ParseDuration(config.Window)
+	if err != nil {
+		return nil, fmt.Errorf("parse idempotency window: %w", err)
+	}
+
+	store := &idempotencyStore{
+		window:     window,
+		maxEntries: config.MaxEntries,
+		entries:    make(map[string]*idempotencyEntry),
+		completed:  list.New(),
+	}
+	if store.maxEntries <= 0 {
+		store.maxEntries = defaultIdempotencyMaxEntries
+	}
+
+	return func(
+		ctx context.Context,
+		method string,
+		req any,
+		reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		key := idempotencyKey(ctx)
+		msg, ok := reply.(proto.Message)
+		r, isProto := req.(proto.Message)
+		if key == "" || !ok || !isProto || !slices.Contains(config.Methods, method) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		content, err := contentKey(method, r)
+		if err != nil {
+			return fmt.Errorf("idempotency: %w", err)
+		}
+
+		id := key
+		key = method + "\x00" + key
+		for {
+			entry, found := store.get(key, content)
+
+			// replaying result of another request would hand out e.g. certificate of another CSR
+			if entry.content != content {
+				return &IdempotencyKeyReusedError{Method: method, Key: id}
+			}
+
+			if !found {
+				err := invoker(ctx, method, req, reply, cc, opts...)
+				store.complete(entry, msg, err)
+
+				return err
+			}
+
+			select {
+			case <-entry.done:
+			case <-ctx.Done():
+				return status.FromContextError(ctx.Err()).Err()
+			}
+
+			// failed calls are not replayed, the caller gets a chance to try on its own
+			if entry.err == nil {
+				proto.Merge(msg, entry.reply)
+				for _, opt := range opts {
+					if o, ok := opt.(ReplayedCallOption); ok {
+						*o.Replayed = true
+					}
+				}
+
+				return nil
+			}
+		}
+	}, nil
+}
+
+// idempotencyKey returns idempotency key carried by outgoing metadata.
+func idempotencyKey(ctx context.Context) string {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if values := md.Get(IdempotencyKeyHeader); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
@@ -0,0 +1,171 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// csr is the request of signing calls in tests.
+var csr = &wrapperspb.StringValue{Value: "csr"}
+
+func TestIdempotency_Replay(t *testing.T) {
+	interceptor, err := Idempotency(IdempotencyConfig{Window: "1m", Methods: []string{"/test.Service/Sign"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var calls atomic.Int32
+	invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		n := calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		reply.(*wrapperspb.Int32Value).Value = n
+		return nil
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), IdempotencyKeyHeader, "123")
+
+	// concurrent and subsequent calls with the same key share the first result.
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			reply := &wrapperspb.Int32Value{}
+			if err := interceptor(ctx, "/test.Service/Sign", csr, reply, nil, invoker); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if reply.GetValue() != 1 {
+				t.Errorf("expected replayed result 1, got %d", reply.GetValue())
+			}
+		})
+	}
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("expected single call to reach invoker, got %d", calls.Load())
+	}
+
+	// other keys and methods are not affected.
+	otherKey := metadata.AppendToOutgoingContext(context.Background(), IdempotencyKeyHeader, "456")
+	_ = interceptor(otherKey, "/test.Service/Sign", csr, &wrapperspb.Int32Value{}, nil, invoker)
+	_ = interceptor(ctx, "/test.Service/Hash", csr, &wrapperspb.Int32Value{}, nil, invoker)
+	_ = interceptor(context.Background(), "/test.Service/Sign", csr, &wrapperspb.Int32Value{}, nil, invoker)
+
+	if calls.Load() != 4 {
+		t.Fatalf("expected 4 calls to reach invoker, got %d", calls.Load())
+	}
+}
+
+func TestIdempotency_FailuresAndExpiry(t *testing.T) {
+	interceptor, err := Idempotency(IdempotencyConfig{Window: "10ms", Methods: []string{"/test.Service/Sign"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	calls := 0
+	fail := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		return status.Error(codes.Unavailable, "failure")
+	}
+	success := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		return nil
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), IdempotencyKeyHeader, "123")
+
+	// failed results are not replayed.
+	_ = interceptor(ctx, "/test.Service/Sign", csr, &wrapperspb.Int32Value{}, nil, fail)
+	_ = interceptor(ctx, "/test.Service/Sign", csr, &wrapperspb.Int32Value{}, nil, success)
+	_ = interceptor(ctx, "/test.Service/Sign", csr, &wrapperspb.Int32Value{}, nil, success)
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+
+	// results are replayed only within window.
+	time.Sleep(20 * time.Millisecond)
+	_ = interceptor(ctx, "/test.Service/Sign", csr, &wrapperspb.Int32Value{}, nil, success)
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestIdempotency_KeyReused(t *testing.T) {
+	interceptor, err := Idempotency(IdempotencyConfig{Window: "1m", Methods: []string{"/test.Service/Sign"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	calls := 0
+	invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		reply.(*wrapperspb.StringValue).Value = "certificate of " + req.(*wrapperspb.StringValue).GetValue()
+		return nil
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), IdempotencyKeyHeader, "123")
+
+	reply := &wrapperspb.StringValue{}
+	if err := interceptor(ctx, "/test.Service/Sign", &wrapperspb.StringValue{Value: "A"}, reply, nil, invoker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// result of A is never replayed to B.
+	other := &wrapperspb.StringValue{}
+	err = interceptor(ctx, "/test.Service/Sign", &wrapperspb.StringValue{Value: "B"}, other, nil, invoker)
+
+	var reused *IdempotencyKeyReusedError
+	if !errors.Is(err, ErrIdempotencyKeyReused) || !errors.As(err, &reused) || reused.Key != "123" {
+		t.Fatalf("expected idempotency key reused error, got %v", err)
+	}
+	if other.GetValue() != "" || calls != 1 {
+		t.Fatalf("expected no reply and single call, got %q and %d calls", other.GetValue(), calls)
+	}
+}
+
+func TestIdempotency_MaxEntries(t *testing.T) {
+	interceptor, err := Idempotency(IdempotencyConfig{Window: "1m", Methods: []string{"/test.Service/Sign"}, MaxEntries: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	calls := 0
+	invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		return nil
+	}
+	sign := func(key string) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), IdempotencyKeyHeader, key)
+		if err := interceptor(ctx, "/test.Service/Sign", csr, &wrapperspb.Int32Value{}, nil, invoker); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	sign("1")
+	sign("2")
+	sign("1")
+	if calls != 2 {
+		t.Fatalf("expected 2 calls while results fit, got %d", calls)
+	}
+
+	// the oldest result is dropped.
+	sign("3")
+	sign("2")
+	sign("3")
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+	sign("1")
+	if calls != 4 {
+		t.Fatalf("expected 4 calls after result of 1 was dropped, got %d", calls)
+	}
+}
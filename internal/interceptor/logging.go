@@ -0,0 +1,99 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/retry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+)
+
+// Create and return logging interceptor.
+// It logs every attempt at level, or at warning level if the attempt failed, with
+// method, profile, Metadata.Id, duration, status code and attempt number.
+// Requests are never logged as a whole, so that key material, CSRs and hashed
+// input can not leak into logs. Neither are error messages, which may repeat the request
+// as sent by the server, only the status code and description of the library's own errors.
+// Placed after retry to log each attempt.
+func Logging(level slog.Level, opts ...Option) grpc.UnaryClientInterceptor {
+	o := newOptions(opts)
+
+	return func(
+		ctx context.Context,
+		method string,
+		req any,
+		reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		attrs := []slog.Attr{
+			slog.String("method", method),
+			slog.String("profile", profileOf(req)),
+			slog.String("id", requestID(req)),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("code", resultOf(err)),
+			slog.Int("attempt", attemptOf(ctx)),
+		}
+
+		l := level
+		if err != nil {
+			if description := errorDescription(err); description != "" {
+				attrs = append(attrs, slog.String("error", description))
+			}
+			l = max(level, slog.LevelWarn)
+		}
+
+		o.log().LogAttrs(ctx, l, "crypto broker call", attrs...)
+
+		return err
+	}
+}
+
+// errorDescription returns fixed description of err if it is one of the library's own errors, empty otherwise.
+func errorDescription(err error) string {
+	for _, known := range []error{
+		ErrCircuitOpen,
+		ErrCircuitHalfOpen,
+		ErrMetadataMismatch,
+		ErrOverloaded,
+		ErrRateLimited,
+		ErrIdempotencyKeyReused,
+	} {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+
+	return ""
+}
+
+// requestID returns Metadata.Id of req, if any.
+func requestID(req any) string {
+	if r, ok := req.(interface{ GetMetadata() *protobuf.Metadata }); ok {
+		return r.GetMetadata().GetId()
+	}
+
+	return ""
+}
+
+// attemptOf returns number of the attempt, starting from 1, as announced by the retry interceptor.
+func attemptOf(ctx context.Context) int {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if values := md.Get(retry.AttemptMetadataKey); len(values) > 0 {
+		if retries, err := strconv.Atoi(values[0]); err == nil {
+			return retries + 1
+		}
+	}
+
+	return 1
+}
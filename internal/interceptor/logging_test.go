@@ -0,0 +1,156 @@
+package interceptor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+)
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logging := Logging(slog.LevelDebug, WithLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+
+	retry, err := Retry(RetryConfig{
+		MaxAttempts:          2,
+		InitialBackoff:       "1ms",
+		BackoffMultiplier:    1,
+		RetryableStatusCodes: []codes.Code{14},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	attempts := 0
+	invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		attempts++
+		if attempts == 1 {
+			return status.Error(codes.Unavailable, "temporary failure")
+		}
+
+		return nil
+	}
+
+	// logging runs inside retry, so that every attempt is logged
+	chained := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return logging(ctx, method, req, reply, cc, invoker, opts...)
+	}
+
+	req := &protobuf.SignCertificateRequest{
+		Profile:      "Default",
+		Csr:          "secret-csr",
+		CaPrivateKey: "secret-private-key",
+		CaCert:       "ca-cert",
+		Metadata:     &protobuf.Metadata{Id: "123"},
+	}
+	if err := retry(context.Background(), "/test.Service/Sign", req, nil, nil, chained); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 logged attempts, got %d: %s", len(lines), buf.String())
+	}
+
+	want := []map[string]any{
+		{"level": "WARN", "method": "/test.Service/Sign", "profile": "Default", "id": "123", "code": "Unavailable", "attempt": 1.0},
+		{"level": "DEBUG", "method": "/test.Service/Sign", "profile": "Default", "id": "123", "code": "OK", "attempt": 2.0},
+	}
+	for i, line := range lines {
+		var got map[string]any
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for k, v := range want[i] {
+			if got[k] != v {
+				t.Errorf("attempt %d: expected %s=%v, got %v", i+1, k, v, got[k])
+			}
+		}
+		if _, ok := got["duration"]; !ok {
+			t.Errorf("attempt %d: expected duration to be logged", i+1)
+		}
+	}
+
+	for _, secret := range []string{"secret-csr", "secret-private-key"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("expected %q to be redacted, got %s", secret, buf.String())
+		}
+	}
+}
+
+func TestLogging_HashInputRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	logging := Logging(slog.LevelInfo, WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+
+	invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.InvalidArgument, "invalid input")
+	}
+
+	req := &protobuf.HashDataRequest{Profile: "Default", Input: []byte("secret-input"), Metadata: &protobuf.Metadata{Id: "123"}}
+	_ = logging(context.Background(), "/test.Service/Hash", req, nil, nil, invoker)
+
+	if !strings.Contains(buf.String(), "id=123") || !strings.Contains(buf.String(), "code=InvalidArgument") {
+		t.Errorf("expected call to be logged, got %s", buf.String())
+	}
+	if strings.Contains(buf.String(), "secret-input") {
+		t.Errorf("expected input to be redacted, got %s", buf.String())
+	}
+}
+
+func TestLogging_ErrorMessageRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	logging := Logging(slog.LevelInfo, WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+
+	// the server repeats the request in its error message
+	invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Errorf(codes.InvalidArgument, "invalid request %v", req)
+	}
+
+	_ = logging(context.Background(), "/test.Service/Sign", &protobuf.SignCertificateRequest{
+		Profile:      "Default",
+		Csr:          "secret-csr",
+		CaPrivateKey: "secret-private-key",
+		Metadata:     &protobuf.Metadata{Id: "123"},
+	}, nil, nil, invoker)
+	_ = logging(context.Background(), "/test.Service/Hash", &protobuf.HashDataRequest{
+		Profile:  "Default",
+		Input:    []byte("secret-input"),
+		Metadata: &protobuf.Metadata{Id: "456"},
+	}, nil, nil, invoker)
+
+	if strings.Count(buf.String(), "code=InvalidArgument") != 2 {
+		t.Errorf("expected both calls to be logged, got %s", buf.String())
+	}
+	for _, secret := range []string{"secret-csr", "secret-private-key", "secret-input"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("expected %q to be redacted from error message, got %s", secret, buf.String())
+		}
+	}
+}
+
+func TestLogging_LibraryError(t *testing.T) {
+	var buf bytes.Buffer
+	logging := Logging(slog.LevelInfo, WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+
+	invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return &IdempotencyKeyReusedError{Method: method, Key: "secret-key"}
+	}
+
+	_ = logging(context.Background(), "/test.Service/Sign", &protobuf.SignCertificateRequest{Profile: "Default"}, nil, nil, invoker)
+
+	if !strings.Contains(buf.String(), `error="`+ErrIdempotencyKeyReused.Error()+`"`) {
+		t.Errorf("expected description of the error to be logged, got %s", buf.String())
+	}
+	if strings.Contains(buf.String(), "secret-key") {
+		t.Errorf("expected only fixed description of the error, got %s", buf.String())
+	}
+}
@@ -0,0 +1,87 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+)
+
+// Headers propagating request metadata, so that proxies and server interceptors can see it.
+const (
+	RequestIDHeader     = "x-request-id"
+	CorrelationIDHeader = "x-correlation-id"
+)
+
+// ErrMetadataMismatch is matched by MetadataMismatchError.
+var ErrMetadataMismatch = errors.New("response metadata does not match request")
+
+// MetadataMismatchError reports response echoing Metadata.Id of another request,
+// which indicates that responses got mixed up.
+type MetadataMismatchError struct {
+	Method     string
+	RequestID  string
+	ResponseID string
+}
+
+func (e *MetadataMismatchError) Error() string {
+	return fmt.Sprintf("%s: response metadata id %q does not match request id %q", e.Method, e.ResponseID, e.RequestID)
+}
+
+func (e *MetadataMismatchError) Is(target error) bool {
+	return target == ErrMetadataMismatch
+}
+
+type MetadataConfig struct {
+	// VerifyEcho checks that responses echo Metadata.Id of the request.
+	// Responses without metadata are not checked.
+	VerifyEcho bool `yaml:"verifyEcho"`
+}
+
+// Create and return metadata interceptor.
+// It sends Metadata.Id and TraceContext.CorrelationId of requests as RequestIDHeader and
+// CorrelationIDHeader, and verifies the echo in responses if configured.
+func Metadata(config MetadataConfig) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req any,
+		reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		r, ok := req.(interface{ GetMetadata() *protobuf.Metadata })
+		if !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		m := r.GetMetadata()
+		if id := m.GetId(); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, RequestIDHeader, id)
+		}
+		if correlationID := m.GetTraceContext().GetCorrelationId(); correlationID != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, CorrelationIDHeader, correlationID)
+		}
+
+		if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+			return err
+		}
+
+		if !config.VerifyEcho {
+			return nil
+		}
+
+		if echo, ok := reply.(interface{ GetMetadata() *protobuf.Metadata }); ok {
+			if got := echo.GetMetadata().GetId(); got != "" && got != m.GetId() {
+				return &MetadataMismatchError{Method: method, RequestID: m.GetId(), ResponseID: got}
+			}
+		}
+
+		return nil
+	}
+}
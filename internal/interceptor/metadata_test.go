@@ -0,0 +1,83 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+)
+
+func TestMetadata_Headers(t *testing.T) {
+	interceptor := Metadata(MetadataConfig{})
+
+	var md metadata.MD
+	invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+
+	req := &protobuf.HashDataRequest{Metadata: &protobuf.Metadata{Id: "123", TraceContext: &protobuf.TraceContext{CorrelationId: "abc"}}}
+	if err := interceptor(context.Background(), "/test.Service/Hash", req, &protobuf.HashDataResponse{}, nil, invoker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := md.Get(RequestIDHeader); len(got) != 1 || got[0] != "123" {
+		t.Errorf("expected %s header 123, got %v", RequestIDHeader, got)
+	}
+	if got := md.Get(CorrelationIDHeader); len(got) != 1 || got[0] != "abc" {
+		t.Errorf("expected %s header abc, got %v", CorrelationIDHeader, got)
+	}
+
+	// correlation header is omitted when there is no correlation id
+	req = &protobuf.HashDataRequest{Metadata: &protobuf.Metadata{Id: "456"}}
+	_ = interceptor(context.Background(), "/test.Service/Hash", req, &protobuf.HashDataResponse{}, nil, invoker)
+	if got := md.Get(CorrelationIDHeader); len(got) != 0 {
+		t.Errorf("expected no %s header, got %v", CorrelationIDHeader, got)
+	}
+}
+
+func TestMetadata_VerifyEcho(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  MetadataConfig
+		echo    *protobuf.Metadata
+		wantErr bool
+	}{
+		{name: "matching echo", config: MetadataConfig{VerifyEcho: true}, echo: &protobuf.Metadata{Id: "123"}},
+		{name: "missing echo is not checked", config: MetadataConfig{VerifyEcho: true}},
+		{name: "empty echo id is not checked", config: MetadataConfig{VerifyEcho: true}, echo: &protobuf.Metadata{}},
+		{name: "mismatching echo", config: MetadataConfig{VerifyEcho: true}, echo: &protobuf.Metadata{Id: "456"}, wantErr: true},
+		{name: "mismatch is ignored without verification", echo: &protobuf.Metadata{Id: "456"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptor := Metadata(tt.config)
+			invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				reply.(*protobuf.HashDataResponse).Metadata = tt.echo
+				return nil
+			}
+
+			req := &protobuf.HashDataRequest{Metadata: &protobuf.Metadata{Id: "123"}}
+			err := interceptor(context.Background(), "/test.Service/Hash", req, &protobuf.HashDataResponse{}, nil, invoker)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !tt.wantErr {
+				return
+			}
+
+			var mismatch *MetadataMismatchError
+			if !errors.Is(err, ErrMetadataMismatch) || !errors.As(err, &mismatch) {
+				t.Fatalf("expected metadata mismatch error, got %v", err)
+			}
+			if mismatch.Method != "/test.Service/Hash" || mismatch.RequestID != "123" || mismatch.ResponseID != "456" {
+				t.Errorf("unexpected mismatch %+v", mismatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/metrics"
+)
+
+// Create and return metrics interceptor.
+// It records number of calls and their duration including all retries, labeled by result.
+func Metrics(m metrics.Metrics) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req any,
+		reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		labels := []metrics.Label{
+			{Key: metrics.LabelMethod, Value: method},
+			{Key: metrics.LabelProfile, Value: profileOf(req)},
+			{Key: metrics.LabelCode, Value: resultOf(err)},
+		}
+		m.Add(metrics.Calls, 1, labels...)
+		m.Observe(metrics.CallDuration, time.Since(start).Seconds(), labels...)
+
+		return err
+	}
+}
+
+// resultOf returns gRPC status code name of err, distinguishing errors of the library's interceptors.
+func resultOf(err error) string {
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		return "CircuitOpen"
+	case errors.Is(err, ErrCircuitHalfOpen):
+		return "CircuitHalfOpen"
+	case errors.Is(err, ErrMetadataMismatch):
+		return "MetadataMismatch"
+	case errors.Is(err, ErrOverloaded):
+		return "Overloaded"
+	case errors.Is(err, ErrRateLimited):
+		return "RateLimited"
+	case errors.Is(err, ErrIdempotencyKeyReused):
+		return "IdempotencyKeyReused"
+	default:
+		return status.Code(err).String()
+	}
+}
@@ -0,0 +1,116 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/metrics"
+)
+
+var (
+	methodLabel  = metrics.Label{Key: metrics.LabelMethod, Value: "/test.Service/Test"}
+	profileLabel = metrics.Label{Key: metrics.LabelProfile, Value: "Default"}
+)
+
+func TestMetrics(t *testing.T) {
+	m := metrics.NewMemory()
+	interceptor := Metrics(m)
+
+	results := []error{nil, nil, status.Error(codes.Unavailable, "unavailable"), ErrCircuitOpen}
+	for _, result := range results {
+		invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return result
+		}
+
+		_ = interceptor(context.Background(), "/test.Service/Test", profileRequest("Default"), nil, nil, invoker)
+	}
+
+	for code, want := range map[string]float64{"OK": 2, "Unavailable": 1, "CircuitOpen": 1} {
+		codeLabel := metrics.Label{Key: metrics.LabelCode, Value: code}
+		if got := m.Counter(metrics.Calls, methodLabel, profileLabel, codeLabel); got != want {
+			t.Errorf("expected %v calls with code %s, got %v", want, code, got)
+		}
+	}
+
+	if got := len(m.Histogram(metrics.CallDuration, methodLabel, profileLabel)); got != len(results) {
+		t.Errorf("expected %d observed durations, got %d", len(results), got)
+	}
+}
+
+func TestRetry_Metrics(t *testing.T) {
+	cfg := RetryConfig{
+		MaxAttempts:          3,
+		InitialBackoff:       "1ms",
+		BackoffMultiplier:    1,
+		RetryableStatusCodes: []codes.Code{14},
+	}
+
+	m := metrics.NewMemory()
+	interceptor, err := Retry(cfg, WithMetrics(m))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fail := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.Unavailable, "temporary failure")
+	}
+	success := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+
+	_ = interceptor(context.Background(), "/test.Service/Test", profileRequest("Default"), nil, nil, fail)
+	_ = interceptor(context.Background(), "/test.Service/Test", profileRequest("Default"), nil, nil, success)
+
+	got := m.Histogram(metrics.CallAttempts, methodLabel, profileLabel)
+	if len(got) != 2 || got[0] != 3 || got[1] != 1 {
+		t.Fatalf("expected observed attempts [3 1], got %v", got)
+	}
+}
+
+func TestCircuitBreaker_Metrics(t *testing.T) {
+	cfg := CircuitConfig{
+		Name:                "test",
+		MaxRequests:         1,
+		Interval:            "30s",
+		Timeout:             "1m",
+		ConsecutiveFailures: 1,
+		FailureStatusCodes:  []codes.Code{14},
+		PerProfile:          true,
+	}
+
+	m := metrics.NewMemory()
+	interceptor, err := CircuitBreaker(cfg, WithMetrics(m))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	success := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	fail := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.Unavailable, "failure")
+	}
+
+	nameLabel := metrics.Label{Key: metrics.LabelName, Value: "test"}
+
+	_ = interceptor(context.Background(), "/test.Service/Test", profileRequest("Default"), nil, nil, success)
+	if state, ok := m.Gauge(metrics.CircuitState, nameLabel, methodLabel, profileLabel); !ok || state != 0 {
+		t.Fatalf("expected closed state 0 to be reported, got %v (reported %v)", state, ok)
+	}
+
+	_ = interceptor(context.Background(), "/test.Service/Test", profileRequest("Default"), nil, nil, fail)
+	if state, _ := m.Gauge(metrics.CircuitState, nameLabel, methodLabel, profileLabel); state != 2 {
+		t.Fatalf("expected open state 2 to be reported, got %v", state)
+	}
+
+	// breaker of other profile keeps its own state
+	otherProfile := metrics.Label{Key: metrics.LabelProfile, Value: "Other"}
+	_ = interceptor(context.Background(), "/test.Service/Test", profileRequest("Other"), nil, nil, success)
+	if state, _ := m.Gauge(metrics.CircuitState, nameLabel, methodLabel, otherProfile); state != 0 {
+		t.Fatalf("expected closed state 0 of other profile, got %v", state)
+	}
+}
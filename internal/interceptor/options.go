@@ -0,0 +1,85 @@
+package interceptor
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/metrics"
+)
+
+// Option customizes interceptor beyond its configuration.
+type Option func(*options)
+
+type options struct {
+	logger  *slog.Logger
+	metrics metrics.Metrics
+	clock   Clock
+}
+
+// Clock tells time and waits, so that interceptors depending on time can be tested.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// WithLogger sets logger used by interceptor instead of the default slog logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithMetrics sets metrics receiving measurements of interceptor.
+func WithMetrics(m metrics.Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// WithClock sets clock used by interceptor instead of the system clock.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// log returns configured logger, falling back to the default one at the time of logging.
+func (o options) log() *slog.Logger {
+	if o.logger == nil {
+		return slog.Default()
+	}
+
+	return o.logger
+}
+
+// measure returns configured metrics, discarding measurements if there are none.
+func (o options) measure() metrics.Metrics {
+	if o.metrics == nil {
+		return metrics.Nop{}
+	}
+
+	return o.metrics
+}
+
+// timeSource returns configured clock, falling back to the system clock.
+func (o options) timeSource() Clock {
+	if o.clock == nil {
+		return systemClock{}
+	}
+
+	return o.clock
+}
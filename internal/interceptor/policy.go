@@ -0,0 +1,67 @@
+package interceptor
+
+// policyKey identifies settings resolved for a full gRPC method name and a crypto broker profile.
+// Empty fields stand for "any method" and "any profile" respectively.
+type policyKey struct {
+	method  string
+	profile string
+}
+
+func (k policyKey) String() string {
+	method := k.method
+	if method == "" {
+		method = "*"
+	}
+
+	if k.profile == "" {
+		return method
+	}
+
+	return method + "@" + k.profile
+}
+
+// policyKeys returns every combination of configured method and profile overrides,
+// including the defaults represented by empty method and profile.
+func policyKeys[M, P any](methods map[string]M, profiles map[string]P) []policyKey {
+	methodNames := []string{""}
+	for method := range methods {
+		methodNames = append(methodNames, method)
+	}
+
+	profileNames := []string{""}
+	for profile := range profiles {
+		profileNames = append(profileNames, profile)
+	}
+
+	keys := make([]policyKey, 0, len(methodNames)*len(profileNames))
+	for _, method := range methodNames {
+		for _, profile := range profileNames {
+			keys = append(keys, policyKey{method: method, profile: profile})
+		}
+	}
+
+	return keys
+}
+
+// lookupKey returns key of the most specific settings configured for method and profile.
+func lookupKey[M, P any](methods map[string]M, profiles map[string]P, method, profile string) policyKey {
+	var key policyKey
+	if _, ok := methods[method]; ok {
+		key.method = method
+	}
+
+	if _, ok := profiles[profile]; ok {
+		key.profile = profile
+	}
+
+	return key
+}
+
+// profileOf extracts crypto broker profile from request, if it has any.
+func profileOf(req any) string {
+	if r, ok := req.(interface{ GetProfile() string }); ok {
+		return r.GetProfile()
+	}
+
+	return ""
+}
@@ -0,0 +1,75 @@
+package interceptor
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// PushbackTrailer is the trailer in which server tells client how many milliseconds to wait before retrying.
+// Negative or malformed value tells client not to retry at all.
+const PushbackTrailer = "grpc-retry-pushback-ms"
+
+// IdempotencyKeyHeader is the metadata key carrying idempotency key of a call.
+// Calls of non-idempotent methods are retried only if they carry it.
+const IdempotencyKeyHeader = "idempotency-key"
+
+// pushback holds server pushback of the last attempt of a call.
+// Attempts of a call are sequential, so no synchronization is needed.
+type pushback struct {
+	wait   time.Duration
+	waitOk bool
+	halt   bool
+}
+
+// track wraps invoker so that pushback of every attempt is recorded.
+func (p *pushback) track(invoker grpc.UnaryInvoker) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		var trailer metadata.MD
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Trailer(&trailer))...)
+		p.record(err, trailer)
+
+		return err
+	}
+}
+
+// record extracts pushback from trailer or, if absent, from RetryInfo status details.
+func (p *pushback) record(err error, trailer metadata.MD) {
+	*p = pushback{}
+	if err == nil {
+		return
+	}
+
+	if values := trailer.Get(PushbackTrailer); len(values) > 0 {
+		ms, parseErr := strconv.ParseInt(values[0], 10, 64)
+		if parseErr != nil || ms < 0 {
+			p.halt = true
+			return
+		}
+
+		p.wait, p.waitOk = time.Duration(ms)*time.Millisecond, true
+		return
+	}
+
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			p.wait, p.waitOk = info.GetRetryDelay().AsDuration(), true
+			return
+		}
+	}
+}
+
+// delay returns wait time requested by server, if any.
+func (p *pushback) delay() (time.Duration, bool) {
+	return p.wait, p.waitOk
+}
+
+// stop reports whether server asked not to retry.
+func (p *pushback) stop() bool {
+	return p.halt
+}
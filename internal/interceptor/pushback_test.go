@@ -0,0 +1,152 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestRetry_Pushback(t *testing.T) {
+	// initial backoff is so long that test only finishes in time if pushback is honored
+	cfg := RetryConfig{
+		MaxAttempts:          3,
+		InitialBackoff:       "1h",
+		BackoffMultiplier:    1,
+		RetryableStatusCodes: []codes.Code{14},
+	}
+
+	retryInfo, err := status.New(codes.Unavailable, "busy").WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Millisecond)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		trailer metadata.MD
+		err     error
+		want    int
+	}{
+		{
+			name:    "trailer pushback replaces backoff",
+			trailer: metadata.Pairs(PushbackTrailer, "1"),
+			err:     status.Error(codes.Unavailable, "busy"),
+			want:    3,
+		},
+		{
+			name:    "negative trailer pushback stops retries",
+			trailer: metadata.Pairs(PushbackTrailer, "-1"),
+			err:     status.Error(codes.Unavailable, "busy"),
+			want:    1,
+		},
+		{
+			name:    "malformed trailer pushback stops retries",
+			trailer: metadata.Pairs(PushbackTrailer, "soon"),
+			err:     status.Error(codes.Unavailable, "busy"),
+			want:    1,
+		},
+		{
+			name: "retry info status details replace backoff",
+			err:  retryInfo.Err(),
+			want: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptor, err := Retry(cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			attempts := 0
+			invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				attempts++
+				setTrailer(opts, tt.trailer)
+				return tt.err
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_ = interceptor(ctx, "/test.Service/Test", nil, nil, nil, invoker)
+
+			if attempts != tt.want {
+				t.Fatalf("expected %d attempts, got %d", tt.want, attempts)
+			}
+		})
+	}
+}
+
+func TestRetry_NonIdempotent(t *testing.T) {
+	notIdempotent := false
+	cfg := RetryConfig{
+		MaxAttempts:          3,
+		InitialBackoff:       "1ms",
+		BackoffMultiplier:    1,
+		RetryableStatusCodes: []codes.Code{14},
+		Methods: map[string]RetryConfig{
+			"/test.Service/Sign": {Idempotent: &notIdempotent},
+		},
+	}
+
+	interceptor, err := Retry(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		method string
+		want   int
+	}{
+		{
+			name:   "idempotent method is retried",
+			ctx:    context.Background(),
+			method: "/test.Service/Hash",
+			want:   3,
+		},
+		{
+			name:   "non-idempotent method without idempotency key is not retried",
+			ctx:    context.Background(),
+			method: "/test.Service/Sign",
+			want:   1,
+		},
+		{
+			name:   "non-idempotent method with idempotency key is retried",
+			ctx:    metadata.AppendToOutgoingContext(context.Background(), IdempotencyKeyHeader, "123"),
+			method: "/test.Service/Sign",
+			want:   3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				attempts++
+				return status.Error(codes.Unavailable, "temporary failure")
+			}
+
+			_ = interceptor(tt.ctx, tt.method, nil, nil, nil, invoker)
+
+			if attempts != tt.want {
+				t.Fatalf("expected %d attempts, got %d", tt.want, attempts)
+			}
+		})
+	}
+}
+
+// setTrailer fills trailer requested by grpc.Trailer call option, as real transport would.
+func setTrailer(opts []grpc.CallOption, trailer metadata.MD) {
+	for _, o := range opts {
+		if t, ok := o.(grpc.TrailerCallOption); ok {
+			*t.TrailerAddr = trailer
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/metrics"
+)
+
+// ErrRateLimited is returned when a call exceeds the rate limit and can not wait for it.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// Supported rate limit modes.
+const (
+	// RateLimitWait makes calls wait until the rate limit allows them.
+	RateLimitWait = "wait"
+
+	// RateLimitFailFast makes calls over the rate limit fail with ErrRateLimited immediately.
+	RateLimitFailFast = "failFast"
+)
+
+type RateLimitConfig struct {
+	// Rate of calls per second, zero means unlimited.
+	Rate float64 `yaml:"rate"`
+
+	// (Optional) Burst of calls allowed at once after a quiet period, 1 if zero.
+	Burst uint `yaml:"burst"`
+
+	// (Optional) Mode of calls over the limit, RateLimitWait (default) or RateLimitFailFast.
+	Mode string `yaml:"mode"`
+
+	// (Optional) MaxWait bounds waiting with RateLimitWait, e.g. "1s". Calls that would wait longer,
+	// or past their deadline, fail with ErrRateLimited immediately. They wait as long as their context allows when empty.
+	MaxWait string `yaml:"maxWait"`
+
+	// Methods overrides the settings above for full gRPC method names,
+	// e.g. "/CryptoBroker.CryptoGrpc/SignCertificate". Zero-valued fields are inherited.
+	Methods map[string]RateLimitConfig `yaml:"methods"`
+
+	// Profiles overrides the settings for requests using the given crypto broker profile.
+	// Profile overrides are applied on top of method overrides and get a limiter of their own.
+	Profiles map[string]RateLimitConfig `yaml:"profiles"`
+}
+
+// merge returns copy of c with all non-zero settings of override applied.
+func (c RateLimitConfig) merge(override RateLimitConfig) RateLimitConfig {
+	if override.Rate != 0 {
+		c.Rate = override.Rate
+	}
+	if override.Burst != 0 {
+		c.Burst = override.Burst
+	}
+	if override.Mode != "" {
+		c.Mode = override.Mode
+	}
+	if override.MaxWait != "" {
+		c.MaxWait = override.MaxWait
+	}
+
+	return c
+}
+
+// bucketSettings are resolved settings of token buckets for a method and profile.
+type bucketSettings struct {
+	rate, burst float64
+
+	// maxWait is negative when calls can wait as long as their context allows
+	maxWait time.Duration
+}
+
+// rateLimiters holds lazily created token buckets, one per method and profile with overrides.
+type rateLimiters struct {
+	config   RateLimitConfig
+	settings map[policyKey]bucketSettings
+	options  options
+
+	mu      sync.Mutex
+	buckets map[policyKey]*bucket
+}
+
+// Create and return rate limit interceptor.
+// Every method gets token bucket of its own, profiles with overrides get one per method as well.
+func RateLimit(config RateLimitConfig, opts ...Option) (grpc.UnaryClientInterceptor, error) {
+	rls := &rateLimiters{
+		config:   config,
+		settings: make(map[policyKey]bucketSettings),
+		options:  newOptions(opts),
+		buckets:  make(map[policyKey]*bucket),
+	}
+
+	for _, key := range policyKeys(config.Methods, config.Profiles) {
+		resolved := config
+		if override, ok := config.Methods[key.method]; ok {
+			resolved = resolved.merge(override)
+		}
+		if override, ok := config.Profiles[key.profile]; ok {
+			resolved = resolved.merge(override)
+		}
+
+		settings, err := newBucketSettings(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+
+		rls.settings[key] = settings
+	}
+
+	interceptor := func(
+		ctx context.Context,
+		method string,
+		req any,
+		reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		profile := profileOf(req)
+
+		b := rls.get(method, profile)
+		if b == nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		wait, err := rls.wait(ctx, b)
+		if err != nil {
+			return err
+		}
+
+		rls.options.measure().Observe(metrics.RateLimitWait, wait.Seconds(),
+			metrics.Label{Key: metrics.LabelMethod, Value: method},
+			metrics.Label{Key: metrics.LabelProfile, Value: profile},
+		)
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	return interceptor, nil
+}
+
+// get returns token bucket of given method and profile, creating it on first use.
+// It returns nil if calls are not limited.
+func (rls *rateLimiters) get(method, profile string) *bucket {
+	settingsKey := lookupKey(rls.config.Methods, rls.config.Profiles, method, profile)
+
+	settings := rls.settings[settingsKey]
+	if settings.rate == 0 {
+		return nil
+	}
+
+	stateKey := policyKey{method: method, profile: settingsKey.profile}
+
+	rls.mu.Lock()
+	defer rls.mu.Unlock()
+
+	b, ok := rls.buckets[stateKey]
+	if !ok {
+		b = &bucket{settings: settings, tokens: settings.burst, last: rls.options.timeSource().Now()}
+		rls.buckets[stateKey] = b
+	}
+
+	return b
+}
+
+// wait takes token from b, waiting for it if allowed, and returns how long it waited.
+func (rls *rateLimiters) wait(ctx context.Context, b *bucket) (time.Duration, error) {
+	clock := rls.options.timeSource()
+	now := clock.Now()
+
+	maxWait := b.settings.maxWait
+	if deadline, ok := ctx.Deadline(); ok && (maxWait < 0 || deadline.Sub(now) < maxWait) {
+		maxWait = max(deadline.Sub(now), 0)
+	}
+
+	wait, ok := b.reserve(now, maxWait)
+	if !ok {
+		return 0, fmt.Errorf("%w: next call allowed in %v", ErrRateLimited, wait)
+	}
+	if wait == 0 {
+		return 0, nil
+	}
+
+	select {
+	case <-clock.After(wait):
+		return wait, nil
+	case <-ctx.Done():
+		b.cancel()
+		return 0, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func newBucketSettings(config RateLimitConfig) (bucketSettings, error) {
+	if config.Rate < 0 {
+		return bucketSettings{}, fmt.Errorf("rate must not be negative, got %v", config.Rate)
+	}
+
+	settings := bucketSettings{rate: config.Rate, burst: float64(max(config.Burst, 1)), maxWait: -1}
+
+	switch config.Mode {
+	case "", RateLimitWait:
+	case RateLimitFailFast:
+		settings.maxWait = 0
+		return settings, nil
+	default:
+		return bucketSettings{}, fmt.Errorf("unknown rate limit mode %q", config.Mode)
+	}
+
+	if config.MaxWait != "" {
+		maxWait, err := time.ParseDuration(config.MaxWait)
+		if err != nil {
+			return bucketSettings{}, fmt.Errorf("parse rate limit max wait: %w", err)
+		}
+
+		settings.maxWait = maxWait
+	}
+
+	return settings, nil
+}
+
+// bucket is a token bucket refilled continuously at rate up to burst tokens.
+// Tokens can be reserved ahead, which makes the bucket negative until refilled.
+type bucket struct {
+	settings bucketSettings
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes token at time now and returns how long to wait until it is available.
+// If the wait would exceed maxWait, unless it is negative, no token is taken and false is returned.
+func (b *bucket) reserve(now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.settings.burst, b.tokens+elapsed.Seconds()*b.settings.rate)
+		b.last = now
+	}
+
+	var wait time.Duration
+	if b.tokens < 1 {
+		wait = time.Duration((1 - b.tokens) / b.settings.rate * float64(time.Second))
+	}
+
+	if maxWait >= 0 && wait > maxWait {
+		return wait, false
+	}
+
+	b.tokens--
+
+	return wait, true
+}
+
+// cancel returns token reserved by call that gave up waiting for it.
+func (b *bucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.settings.burst, b.tokens+1)
+}
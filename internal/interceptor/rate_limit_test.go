@@ -0,0 +1,250 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/metrics"
+)
+
+// fakeClock advances only when told to.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+
+	return ch
+}
+
+// Advance moves time forward by d, firing channels of elapsed waits.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+// Waiting returns number of pending waits.
+func (c *fakeClock) Waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.waiters)
+}
+
+func succeed(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	return nil
+}
+
+func TestRateLimit_FailFast(t *testing.T) {
+	clock := newFakeClock()
+	interceptor, err := RateLimit(RateLimitConfig{Rate: 10, Burst: 2, Mode: RateLimitFailFast}, WithClock(clock))
+	if err != nil {
+		t.Fatalf("RateLimit() error = %v", err)
+	}
+
+	ctx := context.Background()
+	call := func() error { return interceptor(ctx, "/svc/Method", nil, nil, nil, succeed) }
+
+	for range 2 {
+		if err := call(); err != nil {
+			t.Fatalf("interceptor() error = %v within burst", err)
+		}
+	}
+	if err := call(); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("interceptor() error = %v over burst, want %v", err, ErrRateLimited)
+	}
+
+	// one token is refilled every 100ms
+	clock.Advance(50 * time.Millisecond)
+	if err := call(); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("interceptor() error = %v before refill, want %v", err, ErrRateLimited)
+	}
+	clock.Advance(50 * time.Millisecond)
+	if err := call(); err != nil {
+		t.Fatalf("interceptor() error = %v after refill", err)
+	}
+}
+
+func TestRateLimit_Wait(t *testing.T) {
+	clock := newFakeClock()
+	m := metrics.NewMemory()
+	interceptor, err := RateLimit(RateLimitConfig{Rate: 10}, WithClock(clock), WithMetrics(m))
+	if err != nil {
+		t.Fatalf("RateLimit() error = %v", err)
+	}
+
+	ctx := context.Background()
+	call := func() error { return interceptor(ctx, "/svc/Method", profileRequest("HSM"), nil, nil, succeed) }
+
+	if err := call(); err != nil {
+		t.Fatalf("interceptor() error = %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- call() }()
+
+	for clock.Waiting() == 0 {
+		select {
+		case err := <-done:
+			t.Fatalf("interceptor() returned %v without waiting", err)
+		default:
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	clock.Advance(100 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatalf("interceptor() error = %v after waiting", err)
+	}
+
+	got := m.Histogram(metrics.RateLimitWait,
+		metrics.Label{Key: metrics.LabelMethod, Value: "/svc/Method"},
+		metrics.Label{Key: metrics.LabelProfile, Value: "HSM"},
+	)
+	if !reflect.DeepEqual(got, []float64{0, 0.1}) {
+		t.Errorf("recorded waits %v, want [0 0.1]", got)
+	}
+}
+
+func TestRateLimit_WaitBounds(t *testing.T) {
+	clock := newFakeClock()
+	interceptor, err := RateLimit(RateLimitConfig{Rate: 1, MaxWait: "500ms"}, WithClock(clock))
+	if err != nil {
+		t.Fatalf("RateLimit() error = %v", err)
+	}
+
+	call := func(ctx context.Context) error { return interceptor(ctx, "/svc/Method", nil, nil, nil, succeed) }
+
+	if err := call(context.Background()); err != nil {
+		t.Fatalf("interceptor() error = %v", err)
+	}
+
+	// next token is due in 1s
+	if err := call(context.Background()); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("interceptor() error = %v over max wait, want %v", err, ErrRateLimited)
+	}
+
+	clock.Advance(600 * time.Millisecond)
+
+	// within max wait, but past deadline of the call
+	ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(200*time.Millisecond))
+	defer cancel()
+	if err := call(ctx); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("interceptor() error = %v past deadline, want %v", err, ErrRateLimited)
+	}
+
+	// cancelled call gives its token back
+	ctx, cancel = context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- call(ctx) }()
+	for clock.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; status.Code(err) != codes.Canceled {
+		t.Errorf("interceptor() error = %v for cancelled call, want code %v", err, codes.Canceled)
+	}
+
+	clock.Advance(400 * time.Millisecond)
+	if err := call(context.Background()); err != nil {
+		t.Errorf("interceptor() error = %v after cancelled reservation, want token available", err)
+	}
+}
+
+func TestRateLimit_PerMethodAndProfile(t *testing.T) {
+	clock := newFakeClock()
+	interceptor, err := RateLimit(RateLimitConfig{
+		Rate: 100,
+		Mode: RateLimitFailFast,
+		Methods: map[string]RateLimitConfig{
+			"/svc/Sign": {Rate: 1},
+		},
+		Profiles: map[string]RateLimitConfig{
+			"HSM": {Rate: 1},
+		},
+	}, WithClock(clock))
+	if err != nil {
+		t.Fatalf("RateLimit() error = %v", err)
+	}
+
+	call := func(method, profile string) error {
+		return interceptor(context.Background(), method, profileRequest(profile), nil, nil, succeed)
+	}
+
+	for _, c := range []struct{ method, profile string }{{"/svc/Sign", "Default"}, {"/svc/Hash", "HSM"}} {
+		if err := call(c.method, c.profile); err != nil {
+			t.Fatalf("interceptor(%s, %s) error = %v", c.method, c.profile, err)
+		}
+		if err := call(c.method, c.profile); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("interceptor(%s, %s) error = %v over limit, want %v", c.method, c.profile, err, ErrRateLimited)
+		}
+	}
+
+	// calls of other methods and profiles have buckets of their own
+	clock.Advance(10 * time.Millisecond)
+	if err := call("/svc/Hash", "Default"); err != nil {
+		t.Errorf("interceptor() error = %v of unaffected method", err)
+	}
+}
+
+func TestRateLimit_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config RateLimitConfig
+	}{
+		{name: "unknown mode", config: RateLimitConfig{Rate: 1, Mode: "drop"}},
+		{name: "negative rate", config: RateLimitConfig{Rate: -1}},
+		{name: "invalid max wait of profile", config: RateLimitConfig{Profiles: map[string]RateLimitConfig{"HSM": {MaxWait: "long"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := RateLimit(tt.config); err == nil {
+				t.Error("RateLimit() error = nil")
+			}
+		})
+	}
+}
@@ -3,11 +3,28 @@ package interceptor
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
+	"slices"
 	"time"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/retry"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/metrics"
+)
+
+// Supported jitter modes of retry backoff.
+const (
+	// JitterNone waits exactly the computed backoff.
+	JitterNone = "none"
+
+	// JitterFull waits random duration between zero and the computed backoff.
+	JitterFull = "full"
+
+	// JitterEqual waits half of the computed backoff plus random duration up to the other half.
+	JitterEqual = "equal"
 )
 
 type RetryConfig struct {
@@ -15,29 +32,256 @@ type RetryConfig struct {
 	InitialBackoff       string       `yaml:"initialBackoff"`
 	BackoffMultiplier    float64      `yaml:"backoffMultiplier"`
 	RetryableStatusCodes []codes.Code `yaml:"retryableStatusCodes"`
+
+	// (Optional) MaxBackoff caps the backoff between two attempts.
+	MaxBackoff string `yaml:"maxBackoff"`
+
+	// (Optional) Jitter randomizes backoff, one of JitterNone (default), JitterFull or JitterEqual.
+	Jitter string `yaml:"jitter"`
+
+	// (Optional) TotalTimeout bounds the duration of a call including all its retries.
+	TotalTimeout string `yaml:"totalTimeout"`
+
+	// (Optional) Idempotent tells whether the method can be safely re-sent, which is the default.
+	// Calls of non-idempotent methods are retried only when they carry IdempotencyKeyHeader metadata.
+	Idempotent *bool `yaml:"idempotent"`
+
+	// (Optional) Budget limits retries across all calls going through the interceptor.
+	// It is shared by all methods and profiles, therefore it is ignored in overrides.
+	Budget *RetryBudgetConfig `yaml:"budget"`
+
+	// Methods overrides the settings above for full gRPC method names,
+	// e.g. "/CryptoBroker.CryptoGrpc/SignCertificate". Zero-valued fields are inherited.
+	Methods map[string]RetryConfig `yaml:"methods"`
+
+	// Profiles overrides the settings for requests using the given crypto broker profile.
+	// Profile overrides are applied on top of method overrides.
+	Profiles map[string]RetryConfig `yaml:"profiles"`
+}
+
+// merge returns copy of c with all non-zero settings of override applied.
+func (c RetryConfig) merge(override RetryConfig) RetryConfig {
+	if override.MaxAttempts != 0 {
+		c.MaxAttempts = override.MaxAttempts
+	}
+	if override.InitialBackoff != "" {
+		c.InitialBackoff = override.InitialBackoff
+	}
+	if override.BackoffMultiplier != 0 {
+		c.BackoffMultiplier = override.BackoffMultiplier
+	}
+	if override.RetryableStatusCodes != nil {
+		c.RetryableStatusCodes = override.RetryableStatusCodes
+	}
+	if override.MaxBackoff != "" {
+		c.MaxBackoff = override.MaxBackoff
+	}
+	if override.Jitter != "" {
+		c.Jitter = override.Jitter
+	}
+	if override.TotalTimeout != "" {
+		c.TotalTimeout = override.TotalTimeout
+	}
+	if override.Idempotent != nil {
+		c.Idempotent = override.Idempotent
+	}
+
+	return c
+}
+
+// retryPolicy holds settings resolved for particular method and profile.
+type retryPolicy struct {
+	opts         []grpc.CallOption
+	backoff      backoff
+	retriable    retry.RetriableFunc
+	retryable    []codes.Code
+	totalTimeout time.Duration
+	requireKey   bool
 }
 
-// Create and return retry interceptor
-func Retry(config RetryConfig) (grpc.UnaryClientInterceptor, error) {
-	initialBackoff, err := time.ParseDuration(config.InitialBackoff)
+// Create and return retry interceptor.
+// Number of attempts made per call is recorded in metrics given with WithMetrics.
+func Retry(config RetryConfig, opts ...Option) (grpc.UnaryClientInterceptor, error) {
+	o := newOptions(opts)
+
+	var budget *retryBudget
+	if config.Budget != nil {
+		var err error
+		if budget, err = newRetryBudget(*config.Budget); err != nil {
+			return nil, err
+		}
+	}
+
+	policies := make(map[policyKey]retryPolicy)
+	for _, key := range policyKeys(config.Methods, config.Profiles) {
+		resolved := config
+		if override, ok := config.Methods[key.method]; ok {
+			resolved = resolved.merge(override)
+		}
+		if override, ok := config.Profiles[key.profile]; ok {
+			resolved = resolved.merge(override)
+		}
+
+		policy, err := newRetryPolicy(resolved, budget)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+
+		policies[key] = policy
+	}
+
+	// Policy options are passed per call, so that options provided by the caller take precedence.
+	interceptor := retry.UnaryClientInterceptor()
+
+	return func(
+		ctx context.Context,
+		method string,
+		req any,
+		reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		policy := policies[lookupKey(config.Methods, config.Profiles, method, profileOf(req))]
+
+		if policy.totalTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, policy.totalTimeout)
+			defer cancel()
+		}
+
+		attempts := 0
+		defer func() {
+			o.measure().Observe(metrics.CallAttempts, float64(attempts),
+				metrics.Label{Key: metrics.LabelMethod, Value: method},
+				metrics.Label{Key: metrics.LabelProfile, Value: profileOf(req)},
+			)
+		}()
+
+		if policy.requireKey && idempotencyKey(ctx) == "" {
+			attempts++
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		next := invoker
+		invoker = func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			attempts++
+			return next(ctx, method, req, reply, cc, opts...)
+		}
+
+		if budget != nil {
+			invoker = budget.track(invoker, policy.retryable)
+		}
+
+		// Server pushback of the last attempt is tracked per call and takes precedence over the policy.
+		pushback := &pushback{}
+		invoker = pushback.track(invoker)
+
+		return interceptor(ctx, method, req, reply, cc, invoker, slices.Concat(policy.opts, []grpc.CallOption{
+			retry.WithBackoff(func(ctx context.Context, attempt uint) time.Duration {
+				if delay, ok := pushback.delay(); ok {
+					return delay
+				}
+
+				return policy.backoff.duration(attempt)
+			}),
+			retry.WithRetriable(func(err error) bool {
+				return !pushback.stop() && policy.retriable(err)
+			}),
+		}, opts)...)
+	}, nil
+}
+
+// newRetryPolicy translates config into retry call options.
+func newRetryPolicy(config RetryConfig, budget *retryBudget) (retryPolicy, error) {
+	backoff, err := newBackoff(config)
 	if err != nil {
-		return nil, fmt.Errorf("parse initial backoff: %w", err)
+		return retryPolicy{}, err
 	}
 
-	interceptor := retry.UnaryClientInterceptor(
-		retry.WithMax(config.MaxAttempts),
-		retry.WithCodes(config.RetryableStatusCodes...),
+	var totalTimeout time.Duration
+	if config.TotalTimeout != "" {
+		if totalTimeout, err = time.ParseDuration(config.TotalTimeout); err != nil {
+			return retryPolicy{}, fmt.Errorf("parse total timeout: %w", err)
+		}
+	}
 
-		retry.WithBackoff(func(ctx context.Context, attempt uint) time.Duration {
-			backoff := float64(initialBackoff)
+	return retryPolicy{
+		opts:    []grpc.CallOption{retry.WithMax(config.MaxAttempts)},
+		backoff: backoff,
+		retriable: func(err error) bool {
+			return slices.Contains(config.RetryableStatusCodes, status.Code(err)) && (budget == nil || budget.allow())
+		},
+		retryable:    config.RetryableStatusCodes,
+		totalTimeout: totalTimeout,
+		requireKey:   config.Idempotent != nil && !*config.Idempotent,
+	}, nil
+}
 
-			for i := uint(0); i < attempt; i++ {
-				backoff *= config.BackoffMultiplier
-			}
+// backoff computes jittered exponential wait time between attempts.
+type backoff struct {
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+	jitter     string
+}
 
-			return time.Duration(backoff)
-		}),
-	)
+func newBackoff(config RetryConfig) (backoff, error) {
+	initial, err := time.ParseDuration(config.InitialBackoff)
+	if err != nil {
+		return backoff{}, fmt.Errorf("parse initial backoff: %w", err)
+	}
 
-	return interceptor, nil
+	var maxBackoff time.Duration
+	if config.MaxBackoff != "" {
+		if maxBackoff, err = time.ParseDuration(config.MaxBackoff); err != nil {
+			return backoff{}, fmt.Errorf("parse max backoff: %w", err)
+		}
+	}
+
+	switch config.Jitter {
+	case "", JitterNone, JitterFull, JitterEqual:
+	default:
+		return backoff{}, fmt.Errorf("unsupported jitter %q, must be one of %q, %q or %q", config.Jitter, JitterNone, JitterFull, JitterEqual)
+	}
+
+	return backoff{
+		initial:    initial,
+		max:        maxBackoff,
+		multiplier: config.BackoffMultiplier,
+		jitter:     config.Jitter,
+	}, nil
+}
+
+// duration returns time to wait before given attempt.
+func (b backoff) duration(attempt uint) time.Duration {
+	wait := float64(b.initial)
+
+	for i := uint(0); i < attempt; i++ {
+		wait *= b.multiplier
+
+		// stop growing once the cap is reached, which also prevents overflow
+		if b.max > 0 && wait >= float64(b.max) {
+			wait = float64(b.max)
+			break
+		}
+	}
+
+	if b.max > 0 && wait > float64(b.max) {
+		wait = float64(b.max)
+	}
+
+	d := time.Duration(wait)
+	if d <= 0 {
+		return 0
+	}
+
+	switch b.jitter {
+	case JitterFull:
+		return rand.N(d + 1)
+	case JitterEqual:
+		return d/2 + rand.N(d-d/2+1)
+	default:
+		return d
+	}
 }
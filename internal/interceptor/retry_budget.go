@@ -0,0 +1,88 @@
+package interceptor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryBudgetConfig configures token bucket shared by all calls of retry interceptor,
+// following the semantics of gRPC retry throttling. Every failed attempt takes one token
+// from the bucket, every successful attempt puts TokenRatio tokens back.
+// Retries are allowed only while the bucket holds more than half of MaxTokens,
+// which stops retry storms against a recovering server.
+type RetryBudgetConfig struct {
+	MaxTokens  float64 `yaml:"maxTokens"`
+	TokenRatio float64 `yaml:"tokenRatio"`
+}
+
+// retryBudget implements token bucket described by RetryBudgetConfig.
+type retryBudget struct {
+	maxTokens  float64
+	tokenRatio float64
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+func newRetryBudget(config RetryBudgetConfig) (*retryBudget, error) {
+	if config.MaxTokens <= 0 {
+		return nil, fmt.Errorf("retry budget max tokens must be positive, got %v", config.MaxTokens)
+	}
+
+	if config.TokenRatio <= 0 {
+		return nil, fmt.Errorf("retry budget token ratio must be positive, got %v", config.TokenRatio)
+	}
+
+	return &retryBudget{
+		maxTokens:  config.MaxTokens,
+		tokenRatio: config.TokenRatio,
+		tokens:     config.MaxTokens,
+	}, nil
+}
+
+// allow reports whether budget permits another retry.
+func (b *retryBudget) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.tokens > b.maxTokens/2
+}
+
+// success returns tokens to the bucket after successful attempt.
+func (b *retryBudget) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.tokens+b.tokenRatio, b.maxTokens)
+}
+
+// failure takes token from the bucket after failed attempt.
+func (b *retryBudget) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = max(b.tokens-1, 0)
+}
+
+// track wraps invoker so that outcome of every attempt is accounted in the budget.
+// Only failures with retryable status codes drain the bucket.
+func (b *retryBudget) track(invoker grpc.UnaryInvoker, retryable []codes.Code) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		switch {
+		case err == nil:
+			b.success()
+		case slices.Contains(retryable, status.Code(err)):
+			b.failure()
+		}
+
+		return err
+	}
+}
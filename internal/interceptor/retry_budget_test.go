@@ -0,0 +1,52 @@
+package interceptor
+
+import "testing"
+
+func TestRetryBudget(t *testing.T) {
+	budget, err := newRetryBudget(RetryBudgetConfig{MaxTokens: 10, TokenRatio: 0.5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !budget.allow() {
+		t.Fatal("expected full budget to allow retries")
+	}
+
+	for range 5 {
+		budget.failure()
+	}
+
+	if budget.allow() {
+		t.Fatal("expected budget at half capacity to deny retries")
+	}
+
+	budget.success()
+	if !budget.allow() {
+		t.Fatal("expected budget above half capacity to allow retries")
+	}
+
+	for range 100 {
+		budget.success()
+	}
+
+	if budget.tokens != 10 {
+		t.Fatalf("expected tokens capped at 10, got %v", budget.tokens)
+	}
+}
+
+func TestRetryBudget_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  RetryBudgetConfig
+	}{
+		{name: "zero max tokens", cfg: RetryBudgetConfig{TokenRatio: 0.1}},
+		{name: "zero token ratio", cfg: RetryBudgetConfig{MaxTokens: 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newRetryBudget(tt.cfg); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package interceptor
 import (
 	"context"
 	"testing"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -35,3 +36,221 @@ func TestRetry_Retries(t *testing.T) {
 		t.Fatalf("expected 3 attempts, got %d", attempts)
 	}
 }
+
+func TestRetry_PerMethodAndProfile(t *testing.T) {
+	cfg := RetryConfig{
+		MaxAttempts:          3,
+		InitialBackoff:       "1ms",
+		BackoffMultiplier:    1,
+		RetryableStatusCodes: []codes.Code{14},
+		Methods: map[string]RetryConfig{
+			"/test.Service/Once": {MaxAttempts: 1},
+		},
+		Profiles: map[string]RetryConfig{
+			"Patient": {MaxAttempts: 5},
+		},
+	}
+
+	interceptor, err := Retry(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		req    any
+		want   int
+	}{
+		{name: "defaults apply to methods without override", method: "/test.Service/Test", want: 3},
+		{name: "method override replaces max attempts", method: "/test.Service/Once", want: 1},
+		{name: "profile override applies to any method", method: "/test.Service/Test", req: profileRequest("Patient"), want: 5},
+		{name: "profile override is applied on top of method override", method: "/test.Service/Once", req: profileRequest("Patient"), want: 5},
+		{name: "unknown profile falls back to method settings", method: "/test.Service/Once", req: profileRequest("Other"), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				attempts++
+				return status.Error(codes.Unavailable, "temporary failure")
+			}
+
+			_ = interceptor(context.Background(), tt.method, tt.req, nil, nil, invoker)
+
+			if attempts != tt.want {
+				t.Fatalf("expected %d attempts, got %d", tt.want, attempts)
+			}
+		})
+	}
+}
+
+func TestRetry_InvalidOverride(t *testing.T) {
+	cfg := RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: "1ms",
+		Methods: map[string]RetryConfig{
+			"/test.Service/Test": {InitialBackoff: "soon"},
+		},
+	}
+
+	if _, err := Retry(cfg); err == nil {
+		t.Fatal("expected error for invalid method override")
+	}
+}
+
+// profileRequest mimics crypto broker requests carrying a profile.
+type profileRequest string
+
+func (p profileRequest) GetProfile() string {
+	return string(p)
+}
+
+func TestBackoff_Duration(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     RetryConfig
+		attempt uint
+		min     time.Duration
+		max     time.Duration
+	}{
+		{
+			name:    "exponential growth without jitter",
+			cfg:     RetryConfig{InitialBackoff: "500ms", BackoffMultiplier: 2},
+			attempt: 3,
+			min:     4 * time.Second,
+			max:     4 * time.Second,
+		},
+		{
+			name:    "growth is capped by max backoff",
+			cfg:     RetryConfig{InitialBackoff: "500ms", BackoffMultiplier: 2, MaxBackoff: "3s"},
+			attempt: 10,
+			min:     3 * time.Second,
+			max:     3 * time.Second,
+		},
+		{
+			name:    "full jitter stays between zero and capped backoff",
+			cfg:     RetryConfig{InitialBackoff: "500ms", BackoffMultiplier: 2, MaxBackoff: "3s", Jitter: JitterFull},
+			attempt: 10,
+			min:     0,
+			max:     3 * time.Second,
+		},
+		{
+			name:    "equal jitter stays between half and full capped backoff",
+			cfg:     RetryConfig{InitialBackoff: "500ms", BackoffMultiplier: 2, MaxBackoff: "3s", Jitter: JitterEqual},
+			attempt: 10,
+			min:     1500 * time.Millisecond,
+			max:     3 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := newBackoff(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for range 100 {
+				if got := b.duration(tt.attempt); got < tt.min || got > tt.max {
+					t.Fatalf("backoff %v out of range [%v, %v]", got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestRetry_InvalidJitter(t *testing.T) {
+	cfg := RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: "1ms",
+		Jitter:         "random",
+	}
+
+	if _, err := Retry(cfg); err == nil {
+		t.Fatal("expected error for unsupported jitter")
+	}
+}
+
+func TestRetry_TotalTimeout(t *testing.T) {
+	cfg := RetryConfig{
+		MaxAttempts:          100,
+		InitialBackoff:       "10ms",
+		BackoffMultiplier:    1,
+		RetryableStatusCodes: []codes.Code{14},
+		TotalTimeout:         "50ms",
+	}
+
+	interceptor, err := Retry(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	attempts := 0
+	invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		attempts++
+		return status.Error(codes.Unavailable, "temporary failure")
+	}
+
+	start := time.Now()
+	err = interceptor(context.Background(), "/test.Service/Test", nil, nil, nil, invoker)
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("retries were not bounded by total timeout, took %v", elapsed)
+	}
+
+	if attempts >= 100 {
+		t.Fatalf("expected retries to stop early, got %d attempts", attempts)
+	}
+}
+
+func TestRetry_Budget(t *testing.T) {
+	cfg := RetryConfig{
+		MaxAttempts:          3,
+		InitialBackoff:       "1ms",
+		BackoffMultiplier:    1,
+		RetryableStatusCodes: []codes.Code{14},
+		Budget:               &RetryBudgetConfig{MaxTokens: 4, TokenRatio: 1},
+	}
+
+	interceptor, err := Retry(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	attempts := 0
+	fail := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		attempts++
+		return status.Error(codes.Unavailable, "temporary failure")
+	}
+	success := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+
+	// budget of 4 tokens allows retries only while more than 2 tokens are left,
+	// so the first call drains it after two failed attempts.
+	_ = interceptor(context.Background(), "/test.Service/Test", nil, nil, nil, fail)
+	if attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", attempts)
+	}
+
+	// budget is shared, so subsequent calls are not retried at all.
+	attempts = 0
+	_ = interceptor(context.Background(), "/test.Service/Other", nil, nil, nil, fail)
+	if attempts != 1 {
+		t.Fatalf("expected 1 attempt with drained budget, got %d", attempts)
+	}
+
+	// successful calls refill the budget.
+	for range 3 {
+		_ = interceptor(context.Background(), "/test.Service/Test", nil, nil, nil, success)
+	}
+
+	attempts = 0
+	_ = interceptor(context.Background(), "/test.Service/Test", nil, nil, nil, fail)
+	if attempts < 2 {
+		t.Fatalf("expected retries with refilled budget, got %d attempts", attempts)
+	}
+}
@@ -0,0 +1,126 @@
+package interceptor
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+)
+
+// TracerName is the instrumentation name of tracer creating client spans.
+const TracerName = "github.com/open-crypto-broker/crypto-broker-client-go"
+
+// Create and return tracing interceptor.
+// It starts a client span per call and fills TraceContext of request metadata
+// with the span, unless the caller has provided one. The span is also injected
+// into outgoing gRPC metadata with propagator, e.g. as W3C traceparent header.
+func Tracing(provider trace.TracerProvider, propagator propagation.TextMapPropagator) grpc.UnaryClientInterceptor {
+	tracer := provider.Tracer(TracerName)
+
+	return func(
+		ctx context.Context,
+		method string,
+		req any,
+		reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		service, name := splitMethod(method)
+		attrs := []attribute.KeyValue{
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.service", service),
+			attribute.String("rpc.method", name),
+		}
+		if profile := profileOf(req); profile != "" {
+			attrs = append(attrs, attribute.String("crypto_broker.profile", profile))
+		}
+
+		ctx, span := tracer.Start(ctx, strings.TrimPrefix(method, "/"),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attrs...),
+		)
+		defer span.End()
+
+		if r, ok := req.(interface{ GetMetadata() *protobuf.Metadata }); ok {
+			fillTraceContext(r.GetMetadata(), span.SpanContext())
+		}
+
+		md, _ := metadata.FromOutgoingContext(ctx)
+		md = md.Copy()
+		propagator.Inject(ctx, metadataCarrier(md))
+		ctx = metadata.NewOutgoingContext(ctx, md)
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		s := status.Convert(err)
+		span.SetAttributes(attribute.Int64("rpc.grpc.status_code", int64(s.Code())))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, s.Message())
+		}
+
+		return err
+	}
+}
+
+// fillTraceContext sets trace fields of m from sc, keeping trace context provided by the caller.
+func fillTraceContext(m *protobuf.Metadata, sc trace.SpanContext) {
+	if m == nil || !sc.IsValid() {
+		return
+	}
+
+	if m.TraceContext == nil {
+		m.TraceContext = &protobuf.TraceContext{}
+	}
+	if m.TraceContext.TraceId != "" {
+		return
+	}
+
+	m.TraceContext.TraceId = sc.TraceID().String()
+	m.TraceContext.SpanId = sc.SpanID().String()
+	m.TraceContext.TraceFlags = sc.TraceFlags().String()
+	m.TraceContext.TraceState = sc.TraceState().String()
+}
+
+// splitMethod splits full gRPC method name into service and method.
+func splitMethod(fullMethod string) (string, string) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "", service
+	}
+
+	return service, method
+}
+
+// metadataCarrier adapts outgoing gRPC metadata to propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
@@ -0,0 +1,101 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	interceptor := Tracing(provider, propagation.TraceContext{})
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	defer parent.End()
+
+	var traceparent string
+	invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		if values := md.Get("traceparent"); len(values) > 0 {
+			traceparent = values[0]
+		}
+
+		return status.Error(codes.Unavailable, "unavailable")
+	}
+
+	req := &protobuf.HashDataRequest{
+		Profile:  "Default",
+		Metadata: &protobuf.Metadata{Id: "123", TraceContext: &protobuf.TraceContext{CorrelationId: "abc"}},
+	}
+	err := interceptor(ctx, "/CryptoBroker.CryptoGrpc/HashData", req, &protobuf.HashDataResponse{}, nil, invoker)
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected invoker error to be returned, got %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 ended span, got %d", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name() != "CryptoBroker.CryptoGrpc/HashData" || span.SpanKind() != trace.SpanKindClient {
+		t.Errorf("unexpected span %q of kind %v", span.Name(), span.SpanKind())
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("expected span to be child of the active span")
+	}
+	if span.Status().Code != otelcodes.Error {
+		t.Errorf("expected span status error, got %v", span.Status().Code)
+	}
+
+	sc := span.SpanContext()
+	want := &protobuf.TraceContext{
+		TraceId:       sc.TraceID().String(),
+		SpanId:        sc.SpanID().String(),
+		TraceFlags:    sc.TraceFlags().String(),
+		CorrelationId: "abc",
+	}
+	got := req.GetMetadata().GetTraceContext()
+	if got.GetTraceId() != want.TraceId || got.GetSpanId() != want.SpanId ||
+		got.GetTraceFlags() != want.TraceFlags || got.GetCorrelationId() != want.CorrelationId {
+		t.Errorf("expected trace context %v, got %v", want, got)
+	}
+
+	wantParent := "00-" + want.TraceId + "-" + want.SpanId + "-" + want.TraceFlags
+	if traceparent != wantParent {
+		t.Errorf("expected traceparent %q, got %q", wantParent, traceparent)
+	}
+}
+
+func TestTracing_KeepsProvidedTraceContext(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+	interceptor := Tracing(provider, propagation.TraceContext{})
+
+	invoker := func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+
+	provided := &protobuf.TraceContext{TraceId: "4bf92f3577b34da6a3ce929d0e0e4736", SpanId: "00f067aa0ba902b7", TraceFlags: "01"}
+	req := &protobuf.HashDataRequest{Metadata: &protobuf.Metadata{Id: "123", TraceContext: provided}}
+
+	if err := interceptor(context.Background(), "/CryptoBroker.CryptoGrpc/HashData", req, nil, nil, invoker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := req.GetMetadata().GetTraceContext()
+	if got.GetTraceId() != "4bf92f3577b34da6a3ce929d0e0e4736" || got.GetSpanId() != "00f067aa0ba902b7" {
+		t.Errorf("expected provided trace context to be kept, got %v", got)
+	}
+}
@@ -0,0 +1,37 @@
+package interceptor
+
+import (
+	"context"
+	"slices"
+
+	"google.golang.org/grpc"
+)
+
+type waitForReadyKey struct{}
+
+// WithWaitForReady returns copy of ctx whose calls wait for the connection to become ready
+// instead of failing fast while it is not, or the other way round. See WaitForReady.
+func WithWaitForReady(ctx context.Context, waitForReady bool) context.Context {
+	return context.WithValue(ctx, waitForReadyKey{}, waitForReady)
+}
+
+// Create and return interceptor applying the choice made with WithWaitForReady to calls.
+// The choice overrides default call options of the connection, calls without it keep them.
+func WaitForReady() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req any,
+		reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if waitForReady, ok := ctx.Value(waitForReadyKey{}).(bool); ok {
+			// opts already include default call options of the connection, the last one wins
+			opts = slices.Concat(opts, []grpc.CallOption{grpc.WaitForReady(waitForReady)})
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
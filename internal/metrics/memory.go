@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"slices"
+	"sync"
+)
+
+// Memory keeps all measurements in memory, so that they can be inspected e.g. in tests.
+type Memory struct {
+	mu     sync.Mutex
+	series []*series
+}
+
+type series struct {
+	name   string
+	labels []Label
+	kind   string
+	values []float64
+}
+
+// NewMemory returns empty in-memory metrics.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Add(name string, value float64, labels ...Label) {
+	m.record("counter", name, value, labels)
+}
+
+func (m *Memory) Observe(name string, value float64, labels ...Label) {
+	m.record("histogram", name, value, labels)
+}
+
+func (m *Memory) Set(name string, value float64, labels ...Label) {
+	m.record("gauge", name, value, labels)
+}
+
+func (m *Memory) record(kind, name string, value float64, labels []Label) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.series {
+		if s.kind == kind && s.name == name && sameLabels(s.labels, labels) {
+			s.values = append(s.values, value)
+			return
+		}
+	}
+
+	m.series = append(m.series, &series{
+		name:   name,
+		labels: slices.Clone(labels),
+		kind:   kind,
+		values: []float64{value},
+	})
+}
+
+// Counter returns sum of counter name over all series having the given labels.
+func (m *Memory) Counter(name string, labels ...Label) float64 {
+	var sum float64
+	for _, v := range m.values("counter", name, labels) {
+		sum += v
+	}
+
+	return sum
+}
+
+// Histogram returns values observed in histogram name over all series having the given labels.
+func (m *Memory) Histogram(name string, labels ...Label) []float64 {
+	return m.values("histogram", name, labels)
+}
+
+// Gauge returns the last value of gauge name set in series having the given labels,
+// or false if there is none.
+func (m *Memory) Gauge(name string, labels ...Label) (float64, bool) {
+	values := m.values("gauge", name, labels)
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	return values[len(values)-1], true
+}
+
+// values returns values of all series of kind and name whose labels include the given ones.
+func (m *Memory) values(kind, name string, labels []Label) []float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var values []float64
+	for _, s := range m.series {
+		if s.kind != kind || s.name != name {
+			continue
+		}
+
+		if !containsLabels(s.labels, labels) {
+			continue
+		}
+
+		values = append(values, s.values...)
+	}
+
+	return values
+}
+
+func sameLabels(a, b []Label) bool {
+	return len(a) == len(b) && containsLabels(a, b)
+}
+
+func containsLabels(labels, subset []Label) bool {
+	for _, l := range subset {
+		if !slices.Contains(labels, l) {
+			return false
+		}
+	}
+
+	return true
+}
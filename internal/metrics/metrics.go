@@ -0,0 +1,66 @@
+// Package metrics defines measurements recorded by the library and their backends.
+package metrics
+
+// Names of metrics recorded by the library.
+const (
+	// Calls counts completed calls, labeled with LabelMethod, LabelProfile and LabelCode.
+	Calls = "crypto_broker.client.calls"
+
+	// CallDuration observes duration of calls including all retries in seconds,
+	// labeled with LabelMethod, LabelProfile and LabelCode.
+	CallDuration = "crypto_broker.client.call.duration"
+
+	// CallAttempts observes number of attempts made per call, labeled with LabelMethod and LabelProfile.
+	CallAttempts = "crypto_broker.client.call.attempts"
+
+	// CircuitState gauges state of circuit breakers, 0 closed, 1 half-open and 2 open,
+	// labeled with LabelName, LabelMethod and LabelProfile. Profile is empty unless the breaker is kept per profile.
+	CircuitState = "crypto_broker.client.circuit_breaker.state"
+
+	// RateLimitWait observes time calls waited for the rate limiter in seconds, labeled with LabelMethod and LabelProfile.
+	// Calls rejected by the limiter are not observed.
+	RateLimitWait = "crypto_broker.client.rate_limit.wait"
+
+	// Hedges counts attempts sent by hedging in addition to the first one, labeled with LabelMethod and LabelProfile.
+	Hedges = "crypto_broker.client.hedges"
+
+	// CacheHits counts calls answered from the result cache, labeled with LabelMethod and LabelProfile.
+	CacheHits = "crypto_broker.client.cache.hits"
+
+	// CacheMisses counts cacheable calls sent to the server, labeled with LabelMethod and LabelProfile.
+	CacheMisses = "crypto_broker.client.cache.misses"
+)
+
+// Keys of labels attached to metrics.
+const (
+	LabelMethod  = "method"
+	LabelProfile = "profile"
+	LabelCode    = "code"
+	LabelName    = "name"
+)
+
+// Label is a key value pair distinguishing series of a metric.
+type Label struct {
+	Key   string
+	Value string
+}
+
+// Metrics receives measurements of the library.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// Add increments counter name by value.
+	Add(name string, value float64, labels ...Label)
+
+	// Observe records value in histogram name.
+	Observe(name string, value float64, labels ...Label)
+
+	// Set sets gauge name to value.
+	Set(name string, value float64, labels ...Label)
+}
+
+// Nop discards all measurements.
+type Nop struct{}
+
+func (Nop) Add(string, float64, ...Label)     {}
+func (Nop) Observe(string, float64, ...Label) {}
+func (Nop) Set(string, float64, ...Label)     {}
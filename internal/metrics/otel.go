@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ScopeName is the instrumentation scope of OpenTelemetry instruments.
+const ScopeName = "github.com/open-crypto-broker/crypto-broker-client-go"
+
+// units of the metrics recorded by the library, others are dimensionless.
+var units = map[string]string{
+	CallDuration:  "s",
+	RateLimitWait: "s",
+}
+
+// OTel records measurements with OpenTelemetry instruments created on first use.
+type OTel struct {
+	meter metric.Meter
+
+	mu         sync.Mutex
+	counters   map[string]metric.Float64Counter
+	histograms map[string]metric.Float64Histogram
+	gauges     map[string]metric.Float64Gauge
+}
+
+// NewOTel returns metrics recorded with meter of provider.
+func NewOTel(provider metric.MeterProvider) *OTel {
+	return &OTel{
+		meter:      provider.Meter(ScopeName),
+		counters:   make(map[string]metric.Float64Counter),
+		histograms: make(map[string]metric.Float64Histogram),
+		gauges:     make(map[string]metric.Float64Gauge),
+	}
+}
+
+func (o *OTel) Add(name string, value float64, labels ...Label) {
+	counter := instrument(o, o.counters, name, func(name string) (metric.Float64Counter, error) {
+		return o.meter.Float64Counter(name, metric.WithUnit(units[name]))
+	})
+	counter.Add(context.Background(), value, metric.WithAttributes(attributes(labels)...))
+}
+
+func (o *OTel) Observe(name string, value float64, labels ...Label) {
+	histogram := instrument(o, o.histograms, name, func(name string) (metric.Float64Histogram, error) {
+		return o.meter.Float64Histogram(name, metric.WithUnit(units[name]))
+	})
+	histogram.Record(context.Background(), value, metric.WithAttributes(attributes(labels)...))
+}
+
+func (o *OTel) Set(name string, value float64, labels ...Label) {
+	gauge := instrument(o, o.gauges, name, func(name string) (metric.Float64Gauge, error) {
+		return o.meter.Float64Gauge(name, metric.WithUnit(units[name]))
+	})
+	gauge.Record(context.Background(), value, metric.WithAttributes(attributes(labels)...))
+}
+
+// instrument returns instrument name from cache, creating it on first use.
+// Instruments failing to be created are still returned, as the OpenTelemetry API
+// then provides a working no-op instrument and reports the error through its handler.
+func instrument[T any](o *OTel, cache map[string]T, name string, create func(string) (T, error)) T {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	i, ok := cache[name]
+	if !ok {
+		i, _ = create(name)
+		cache[name] = i
+	}
+
+	return i
+}
+
+func attributes(labels []Label) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, len(labels))
+	for i, l := range labels {
+		attrs[i] = attribute.String(l.Key, l.Value)
+	}
+
+	return attrs
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestOTel(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	m := NewOTel(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	labels := []Label{{Key: LabelMethod, Value: "/test.Service/Test"}, {Key: LabelCode, Value: "OK"}}
+	m.Add(Calls, 1, labels...)
+	m.Add(Calls, 2, labels...)
+	m.Observe(CallDuration, 0.5, labels...)
+	m.Set(CircuitState, 2, Label{Key: LabelName, Value: "test"})
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(rm.ScopeMetrics) != 1 || rm.ScopeMetrics[0].Scope.Name != ScopeName {
+		t.Fatalf("expected metrics of scope %s, got %+v", ScopeName, rm.ScopeMetrics)
+	}
+
+	got := make(map[string]metricdata.Metrics)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		got[m.Name] = m
+	}
+
+	calls, ok := got[Calls].Data.(metricdata.Sum[float64])
+	if !ok || len(calls.DataPoints) != 1 || calls.DataPoints[0].Value != 3 {
+		t.Errorf("expected counter %s with value 3, got %+v", Calls, got[Calls].Data)
+	} else if v, _ := calls.DataPoints[0].Attributes.Value(attribute.Key(LabelCode)); v.AsString() != "OK" {
+		t.Errorf("expected label %s=OK, got %v", LabelCode, calls.DataPoints[0].Attributes)
+	}
+
+	duration, ok := got[CallDuration].Data.(metricdata.Histogram[float64])
+	if !ok || len(duration.DataPoints) != 1 || duration.DataPoints[0].Sum != 0.5 || got[CallDuration].Unit != "s" {
+		t.Errorf("expected histogram %s with sum 0.5s, got %+v", CallDuration, got[CallDuration])
+	}
+
+	state, ok := got[CircuitState].Data.(metricdata.Gauge[float64])
+	if !ok || len(state.DataPoints) != 1 || state.DataPoints[0].Value != 2 {
+		t.Errorf("expected gauge %s with value 2, got %+v", CircuitState, got[CircuitState].Data)
+	}
+}
@@ -3,16 +3,21 @@ package cryptobrokerclientgo
 import (
 	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"net"
 	"path/filepath"
+	"slices"
+	"sync"
 	"time"
 
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/balancer"
 	"github.com/open-crypto-broker/crypto-broker-client-go/internal/interceptor"
 	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
 
+	"go.opentelemetry.io/otel/propagation"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/health/grpc_health_v1"
@@ -28,10 +33,57 @@ var (
 	defaultSocketPath = filepath.Join(baseDir, "crypto-broker-server.sock")
 )
 
+// gRPC metadata keys sent with requests.
+const (
+	// IdempotencyKeyHeader carries idempotency key of signing requests.
+	IdempotencyKeyHeader = interceptor.IdempotencyKeyHeader
+
+	// RequestIDHeader carries Metadata.Id of requests.
+	RequestIDHeader = interceptor.RequestIDHeader
+
+	// CorrelationIDHeader carries TraceContext.CorrelationId of requests, if any.
+	CorrelationIDHeader = interceptor.CorrelationIDHeader
+)
+
+// ErrClosed is returned by calls made after Close or Shutdown.
+var ErrClosed = errors.New("crypto broker library is closed")
+
 // Errors returned from interceptors.
 var (
 	ErrCircuitOpen     = interceptor.ErrCircuitOpen
 	ErrCircuitHalfOpen = interceptor.ErrCircuitHalfOpen
+
+	// ErrMetadataMismatch is matched by MetadataMismatchError.
+	ErrMetadataMismatch = interceptor.ErrMetadataMismatch
+
+	// ErrOverloaded is returned when concurrency limit is reached and the call can not be queued
+	// or waited in the queue for too long, see ConcurrencyConfig.
+	ErrOverloaded = interceptor.ErrOverloaded
+
+	// ErrRateLimited is returned when rate limit is exceeded and the call can not wait for it, see RateLimitConfig.
+	ErrRateLimited = interceptor.ErrRateLimited
+
+	// ErrIdempotencyKeyReused is matched by IdempotencyKeyReusedError.
+	ErrIdempotencyKeyReused = interceptor.ErrIdempotencyKeyReused
+)
+
+// MetadataMismatchError reports response echoing Metadata.Id of another request,
+// which indicates that responses got mixed up. It is only returned when
+// MetadataConfig.VerifyEcho is set.
+type MetadataMismatchError = interceptor.MetadataMismatchError
+
+// IdempotencyKeyReusedError reports signing request carrying Metadata.Id of an earlier request
+// with another content, e.g. another CSR. It is returned instead of replaying the earlier certificate.
+type IdempotencyKeyReusedError = interceptor.IdempotencyKeyReusedError
+
+// Full gRPC method names of the crypto broker services.
+// They can be used as keys of per-method retry and circuit breaker settings.
+const (
+	MethodHashData        = "/CryptoBroker.CryptoGrpc/HashData"
+	MethodSignCertificate = "/CryptoBroker.CryptoGrpc/SignCertificate"
+	MethodBenchmark       = "/CryptoBroker.CryptoGrpcDev/Benchmark"
+	MethodFakeEndpoint    = "/CryptoBroker.CryptoGrpcDev/FakeEndpoint"
+	MethodHealthCheck     = "/grpc.health.v1.Health/Check"
 )
 
 // Library implements convenient facade to work with crypto broker
@@ -40,89 +92,348 @@ type Library struct {
 	development  protobuf.CryptoGrpcDevClient
 	healthClient grpc_health_v1.HealthClient
 	conn         *grpc.ClientConn
+	auditSink    AuditSink
+	cache        *interceptor.Cache
+
+	// async runs operations of the asynchronous API, see runAsync
+	async asyncPool
+
+	// lifecycle of the connection, see begin, Shutdown and Close
+	mu        sync.Mutex
+	closing   bool
+	closed    bool
+	inflight  sync.WaitGroup
+	closeOnce sync.Once
 }
 
 type GrpcConfig struct {
-	ConnMaxRetries int
+	// ConnectTimeout bounds waiting for the connection to become ready when the library is created,
+	// e.g. "30s" in YAML. ConnMaxRetries seconds are used when zero. It is not used in lazy mode.
+	ConnectTimeout time.Duration `yaml:"connectTimeout"`
+
+	// Deprecated: ConnMaxRetries is the connect timeout in seconds, use ConnectTimeout instead.
+	ConnMaxRetries int `yaml:"connMaxRetries"`
+
+	// (Optional) Lazy makes New return without waiting for the connection, which is then
+	// established on first use. See WithOnReady to learn when the broker became reachable.
+	Lazy bool `yaml:"lazy"`
+
+	// (Optional) ReconnectMaxBackoff caps the delay between attempts to re-establish lost connection.
+	// gRPC default of 120s is used when empty.
+	ReconnectMaxBackoff string `yaml:"reconnectMaxBackoff"`
+
+	// (Optional) PoolSize opens that many connections to every endpoint and spreads calls across them,
+	// which raises throughput limited by a single HTTP/2 connection. All of them share the interceptors.
+	PoolSize int `yaml:"poolSize"`
+
+	// (Optional) WaitForReady makes calls wait until the connection is ready instead of failing fast,
+	// see WithWaitForReady to choose per call.
+	WaitForReady bool `yaml:"waitForReady"`
+}
+
+// connectTimeout returns ConnectTimeout, falling back to ConnMaxRetries seconds.
+func (c GrpcConfig) connectTimeout() time.Duration {
+	if c.ConnectTimeout > 0 {
+		return c.ConnectTimeout
+	}
+
+	return time.Duration(c.ConnMaxRetries) * time.Second
 }
 
 // NewLibrary returns pointer to GrpcLibrary instance.
 // Internally it establishes connection to the gRPC server,
 // configures provided unary interceptors and grpc server, verifies connectivity,
 // or returns non-nil error if any occures.
+//
+// Configs may contain Options as well as Config (or *Config, as returned by LoadConfig), GrpcConfig
+// and interceptor configurations. They are applied in order, so later values replace earlier ones.
+// Values of any other type result in ErrUnknownConfig. New offers the same in type-safe manner.
 func NewLibrary(ctx context.Context, configs ...any) (*Library, error) {
-	// Create a custom dialer for Unix domain sockets
-	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
-		return net.Dial("unix", defaultSocketPath)
+	opts := make([]Option, 0, len(configs))
+	for _, conf := range configs {
+		opt, err := legacyOption(conf)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, opt)
 	}
 
-	// Set default GRPC server configuration
-	grpcConfig := GrpcConfig{ConnMaxRetries: 60}
+	return New(ctx, opts...)
+}
 
-	// Create default interceptors
-	retry, err := retryInterceptor()
+// New returns pointer to Library instance configured with given options.
+// Internally it establishes connection to the gRPC server, verifies connectivity within
+// GrpcConfig.ConnectTimeout, or returns non-nil error if any occures. In lazy mode,
+// see WithLazyConnect, it returns without verifying connectivity.
+//
+// Unary interceptors are chained in the following order, from the outermost:
+//  1. interceptors added with WithUnaryInterceptors, once per call,
+//  2. tracing, if enabled with WithTracerProvider,
+//  3. metrics of calls, if enabled with WithMetrics,
+//  4. wait-for-ready choice of WithWaitForReady, request metadata headers and echo verification,
+//  5. result cache,
+//  6. coalescing of identical concurrent calls,
+//  7. idempotency replay of signing results,
+//  8. retry, repeating the rest of the chain for every attempt,
+//  9. hedging, repeating the rest of the chain for every hedged attempt,
+//  10. logging of attempts, if enabled with WithCallLogging,
+//  11. circuit breaker,
+//  12. rate limit, waiting for a token,
+//  13. concurrency limit, queueing attempts for a slot,
+//  14. interceptors added with WithAppendedUnaryInterceptors, once per attempt.
+func New(ctx context.Context, opts ...Option) (*Library, error) {
+	start := time.Now()
+
+	s := settings{config: DefaultConfig()}
+	for _, opt := range opts {
+		if err := opt(&s); err != nil {
+			return nil, fmt.Errorf("invalid option: %w", err)
+		}
+	}
+
+	config := s.config
+
+	var interceptorOpts []interceptor.Option
+	if s.logger != nil {
+		interceptorOpts = append(interceptorOpts, interceptor.WithLogger(s.logger))
+	}
+	if s.metrics != nil {
+		interceptorOpts = append(interceptorOpts, interceptor.WithMetrics(s.metrics))
+	}
+
+	// Create interceptors
+	retry, err := interceptor.Retry(config.Retry, interceptorOpts...)
 	if err != nil {
 		return nil, err
 	}
 
-	breaker, err := circuitBreakerInterceptor()
+	breaker, err := interceptor.CircuitBreaker(config.CircuitBreaker, interceptorOpts...)
 	if err != nil {
 		return nil, err
 	}
 
-	// Apply custom configurations
-	for _, conf := range configs {
-		switch t := conf.(type) {
-		case interceptor.RetryConfig:
-			retry, err = interceptor.Retry(t)
-		case interceptor.CircuitConfig:
-			breaker, err = interceptor.CircuitBreaker(t)
-		case GrpcConfig:
-			grpcConfig = t
+	idempotency, err := interceptor.Idempotency(config.Idempotency)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency, err := interceptor.ConcurrencyLimit(config.Concurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimit, err := interceptor.RateLimit(config.RateLimit, interceptorOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := interceptor.NewCache(config.Cache, interceptorOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	hedging, err := interceptor.Hedging(config.Hedging, config.Retry, interceptorOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Optional interceptors are only chained when enabled, see the order documented above
+	interceptors := slices.Clone(s.unaryInterceptors)
+	if s.tracerProvider != nil {
+		propagator := s.propagator
+		if propagator == nil {
+			propagator = propagation.TraceContext{}
 		}
 
+		interceptors = append(interceptors, interceptor.Tracing(s.tracerProvider, propagator))
+	}
+	if s.metrics != nil {
+		interceptors = append(interceptors, interceptor.Metrics(s.metrics))
+	}
+
+	interceptors = append(interceptors, interceptor.WaitForReady(), interceptor.Metadata(config.Metadata),
+		cache.Interceptor(), interceptor.Coalescing(config.Coalescing), idempotency, retry, hedging)
+	if s.callLogging != nil {
+		interceptors = append(interceptors, interceptor.Logging(*s.callLogging, interceptorOpts...))
+	}
+
+	interceptors = append(interceptors, breaker, rateLimit, concurrency)
+	interceptors = append(interceptors, s.appendedUnaryInterceptors...)
+
+	// Create a custom dialer for Unix domain sockets
+	target := "unix://" + config.Endpoint
+	transport := []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return net.Dial("unix", config.Endpoint)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+
+	if len(config.LoadBalancing.Endpoints) > 0 || config.Grpc.PoolSize > 1 {
+		loadBalancing := config.LoadBalancing
+		if len(loadBalancing.Endpoints) == 0 {
+			loadBalancing.Endpoints = []EndpointConfig{{Endpoint: config.Endpoint}}
+		}
+
+		// balanced endpoints and pooled connections are dialed with network and TLS settings of their endpoint
+		target, transport, err = balancer.DialOptions(loadBalancing, config.Grpc.PoolSize)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("invalid load balancing: %w", err)
 		}
 	}
 
-	conn, err := grpc.NewClient("unix://"+defaultSocketPath,
-		grpc.WithContextDialer(dialer),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithChainUnaryInterceptor(
-			retry,
-			breaker,
-		),
-	)
+	dialOpts := []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(interceptors...),
+		grpc.WithChainStreamInterceptor(s.streamInterceptors...),
+		grpc.WithDefaultCallOptions(grpc.WaitForReady(config.Grpc.WaitForReady)),
+	}
 
+	if config.Grpc.ReconnectMaxBackoff != "" {
+		maxDelay, err := time.ParseDuration(config.Grpc.ReconnectMaxBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("parse reconnect max backoff: %w", err)
+		}
+
+		params := grpc.ConnectParams{Backoff: backoff.DefaultConfig, MinConnectTimeout: 20 * time.Second}
+		params.Backoff.MaxDelay = maxDelay
+		params.Backoff.BaseDelay = min(params.Backoff.BaseDelay, maxDelay)
+		dialOpts = append(dialOpts, grpc.WithConnectParams(params))
+	}
+
+	dialOpts = append(dialOpts, transport...)
+	dialOpts = append(dialOpts, s.dialOptions...)
+
+	conn, err := grpc.NewClient(target, dialOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("could not create gRPC client, err: %w", err)
 	}
 
 	lib := &Library{
 		client:       protobuf.NewCryptoGrpcClient(conn),
+		development:  protobuf.NewCryptoGrpcDevClient(conn),
 		healthClient: grpc_health_v1.NewHealthClient(conn),
 		conn:         conn,
+		auditSink:    s.auditSink,
+		cache:        cache,
+		async:        asyncPool{workers: s.asyncWorkers},
+	}
+
+	if config.Grpc.Lazy {
+		if s.onReady != nil {
+			go lib.notifyReady(start, s.onReady)
+		}
+
+		return lib, nil
 	}
 
-	duration := time.Duration(grpcConfig.ConnMaxRetries) * time.Second
-	ctxTimeout, cancel := context.WithTimeout(ctx, duration)
+	ctxTimeout, cancel := context.WithTimeout(ctx, config.Grpc.connectTimeout())
 	defer cancel()
 
 	if err = lib.verifyConnection(ctxTimeout); err != nil {
+		_ = conn.Close()
 		return nil, fmt.Errorf("could not establish connection to gRPC server, err: %w", err)
 	}
 
+	if s.onReady != nil {
+		s.onReady(time.Since(start))
+	}
+
 	return lib, nil
 }
 
-// Close closes established gRPC connection.
+// Close closes established gRPC connection immediately, cancelling calls in flight.
+// Calls made afterwards, including asynchronous ones still queued, return ErrClosed. Close can be called multiple times,
+// only the first call closes the connection and may return an error.
 func (lib *Library) Close() error {
-	if lib.conn == nil {
-		return fmt.Errorf("missing connection, nothing to close")
+	lib.stopAccepting()
+
+	return lib.closeConn()
+}
+
+// Shutdown stops accepting new calls, which return ErrClosed, and waits for calls in flight to finish.
+// If ctx is done first, the connection is closed anyway, cancelling the remaining calls,
+// and the context error is returned.
+func (lib *Library) Shutdown(ctx context.Context) error {
+	lib.stopAccepting()
+
+	drained := make(chan struct{})
+	go func() {
+		lib.inflight.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = fmt.Errorf("calls in flight did not finish before shutdown deadline: %w", ctx.Err())
 	}
 
-	return lib.conn.Close()
+	if closeErr := lib.closeConn(); closeErr != nil {
+		return closeErr
+	}
+
+	return err
+}
+
+// begin registers call in flight, it must be finished by calling the returned function.
+// It returns ErrClosed once the library is closed.
+func (lib *Library) begin() (func(), error) {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+
+	if lib.closing {
+		return nil, ErrClosed
+	}
+
+	lib.inflight.Add(1)
+
+	return lib.inflight.Done, nil
+}
+
+func (lib *Library) stopAccepting() {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+
+	lib.closing = true
+}
+
+// closeConn closes the connection once.
+func (lib *Library) closeConn() error {
+	var err error
+	lib.closeOnce.Do(func() {
+		lib.mu.Lock()
+		lib.closed = true
+		lib.mu.Unlock()
+
+		if lib.conn != nil {
+			err = lib.conn.Close()
+		}
+	})
+
+	return err
+}
+
+// isClosed reports whether the connection is closed.
+func (lib *Library) isClosed() bool {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+
+	return lib.closed
+}
+
+// notifyReady calls onReady with time elapsed since start once the connection first becomes ready.
+// It does not initiate the connection and gives up when the connection is closed.
+func (lib *Library) notifyReady(start time.Time, onReady func(time.Duration)) {
+	for state := lib.conn.GetState(); state != connectivity.Shutdown; state = lib.conn.GetState() {
+		if state == connectivity.Ready {
+			onReady(time.Since(start))
+			return
+		}
+
+		lib.conn.WaitForStateChange(context.Background(), state)
+	}
 }
 
 // verifyConnection verifies connection between client and server in given context window
@@ -150,25 +461,3 @@ func (lib *Library) verifyConnection(ctx context.Context) error {
 		state = lib.conn.GetState()
 	}
 }
-
-// Create and return default retry interceptor.
-func retryInterceptor() (grpc.UnaryClientInterceptor, error) {
-	return interceptor.Retry(interceptor.RetryConfig{
-		MaxAttempts:          5,
-		InitialBackoff:       "500ms",
-		BackoffMultiplier:    2.0,
-		RetryableStatusCodes: []codes.Code{14, 8, 10},
-	})
-}
-
-// Create and return default circuit breaker interceptor.
-func circuitBreakerInterceptor() (grpc.UnaryClientInterceptor, error) {
-	return interceptor.CircuitBreaker(interceptor.CircuitConfig{
-		Name:                "crypto-grpc",
-		MaxRequests:         3,
-		Interval:            "30s",
-		Timeout:             "5s",
-		ConsecutiveFailures: 3,
-		FailureStatusCodes:  []codes.Code{14, 8, 10},
-	})
-}
@@ -2,11 +2,16 @@ package cryptobrokerclientgo
 
 import (
 	"context"
-	"strings"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/open-crypto-broker/crypto-broker-client-go/internal/interceptor"
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestNewLibrary(t *testing.T) {
@@ -59,11 +64,165 @@ func TestNewLibrary(t *testing.T) {
 
 func TestLibrary_Close(t *testing.T) {
 	lib := &Library{conn: nil}
-	err := lib.Close()
-	if err == nil {
-		t.Fatalf("Library.Close() expected error, got nil")
+	for range 2 {
+		if err := lib.Close(); err != nil {
+			t.Fatalf("Library.Close() error = %v, want nil", err)
+		}
 	}
-	if !strings.Contains(err.Error(), "missing connection") {
-		t.Fatalf("Library.Close() error = %q, want it to mention missing connection", err.Error())
+
+	if _, err := lib.HashData(context.Background(), HashDataPayload{}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Library.HashData() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestLibrary_CloseAfterUse(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx, WithEndpoint(broker.socket))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := lib.Close(); err != nil {
+		t.Fatalf("Library.Close() error = %v", err)
+	}
+	if err := lib.Close(); err != nil {
+		t.Fatalf("second Library.Close() error = %v, want nil", err)
+	}
+
+	if _, err := lib.HashData(ctx, HashDataPayload{Profile: "Default", Input: []byte("Hello world")}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Library.HashData() error = %v, want %v", err, ErrClosed)
+	}
+	if _, err := lib.SignCertificate(ctx, SignCertificatePayload{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Library.SignCertificate() error = %v, want %v", err, ErrClosed)
+	}
+	if _, err := lib.BenchmarkData(ctx, BenchmarkDataPayload{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Library.BenchmarkData() error = %v, want %v", err, ErrClosed)
+	}
+	if _, err := lib.FakeEndpoint(ctx, FakeEndpointPayload{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Library.FakeEndpoint() error = %v, want %v", err, ErrClosed)
+	}
+	if got := lib.HealthData(ctx); got.Status != StatusUnknown {
+		t.Errorf("Library.HealthData() status = %v, want %v", got.Status, StatusUnknown)
+	}
+}
+
+func TestLibrary_Shutdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		wantErr  error
+		wantCode codes.Code
+	}{
+		{name: "Shutdown() waits for calls in flight", timeout: 10 * time.Second, wantCode: codes.OK},
+		{name: "Shutdown() closes connection on deadline", timeout: 50 * time.Millisecond, wantErr: context.DeadlineExceeded, wantCode: codes.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := newTestBroker(t)
+			started, release := make(chan struct{}), make(chan struct{})
+			hashData := broker.hashData
+			var calls atomic.Int32
+			broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+				// only the first call is kept in flight
+				if calls.Add(1) == 1 {
+					close(started)
+					select {
+					case <-release:
+					case <-ctx.Done():
+					}
+				}
+				return hashData(ctx, req)
+			}
+			broker.start(t)
+			defer broker.stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			lib, err := New(ctx, WithEndpoint(broker.socket), WithRetry(RetryConfig{MaxAttempts: 1, InitialBackoff: "1ms"}))
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			result := make(chan error, 1)
+			go func() {
+				_, err := lib.HashData(ctx, HashDataPayload{Profile: "Default", Input: []byte("Hello world")})
+				result <- err
+			}()
+			<-started
+
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer shutdownCancel()
+
+			shutdown := make(chan error, 1)
+			go func() { shutdown <- lib.Shutdown(shutdownCtx) }()
+
+			// new calls are rejected as soon as shutdown begins
+			for {
+				_, err := lib.HashData(ctx, HashDataPayload{Profile: "Default", Input: []byte("Hello world")})
+				if errors.Is(err, ErrClosed) {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			if tt.wantErr == nil {
+				close(release)
+			}
+
+			if err := <-shutdown; !errors.Is(err, tt.wantErr) {
+				t.Errorf("Library.Shutdown() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := <-result; status.Code(err) != tt.wantCode {
+				t.Errorf("Library.HashData() in flight error = %v, want code %v", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestLibrary_ConcurrentCloseAndCalls(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx, WithEndpoint(broker.socket), WithRetry(RetryConfig{MaxAttempts: 1, InitialBackoff: "1ms"}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for range 20 {
+				_, err := lib.HashData(ctx, HashDataPayload{Profile: "Default", Input: []byte("Hello world")})
+				if err != nil && !errors.Is(err, ErrClosed) && status.Code(err) != codes.Canceled {
+					t.Errorf("Library.HashData() unexpected error = %v", err)
+				}
+			}
+		})
+	}
+	for range 2 {
+		wg.Go(func() {
+			if err := lib.Close(); err != nil {
+				t.Errorf("Library.Close() error = %v", err)
+			}
+		})
+	}
+	wg.Go(func() {
+		_ = lib.Shutdown(ctx)
+	})
+	wg.Wait()
+
+	if _, err := lib.HashData(ctx, HashDataPayload{Profile: "Default", Input: []byte("Hello world")}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Library.HashData() after close error = %v, want %v", err, ErrClosed)
 	}
 }
@@ -0,0 +1,105 @@
+package cryptobrokerclientgo
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+)
+
+// TraceContext carries distributed tracing data of a request.
+type TraceContext struct {
+	TraceId       string
+	SpanId        string
+	TraceFlags    string
+	TraceState    string
+	CorrelationId string
+}
+
+// Metadata identifies a request to track it back.
+type Metadata struct {
+	Id           string
+	TraceContext *TraceContext
+}
+
+type metadataKey struct{}
+
+// WithMetadata returns copy of ctx carrying metadata used by all calls made with it,
+// e.g. to pass request-scoped trace context set at the edge of a service.
+// Metadata of payloads takes precedence field by field, and a new Id is generated
+// for every call unless one is set. The Id set with metadata is sent with every call,
+// but only an Id set in the payload is used as idempotency key of signing, see SignCertificate.
+func WithMetadata(ctx context.Context, metadata Metadata) context.Context {
+	if metadata.TraceContext != nil {
+		tc := *metadata.TraceContext
+		metadata.TraceContext = &tc
+	}
+
+	return context.WithValue(ctx, metadataKey{}, metadata)
+}
+
+// WithCorrelationID returns copy of ctx whose calls carry id as TraceContext.CorrelationId,
+// keeping other metadata set with WithMetadata.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	metadata, _ := MetadataFromContext(ctx)
+	if metadata.TraceContext == nil {
+		metadata.TraceContext = &TraceContext{}
+	}
+
+	metadata.TraceContext.CorrelationId = id
+
+	return WithMetadata(ctx, metadata)
+}
+
+// MetadataFromContext returns metadata set with WithMetadata or WithCorrelationID, if any.
+func MetadataFromContext(ctx context.Context) (Metadata, bool) {
+	metadata, ok := ctx.Value(metadataKey{}).(Metadata)
+	if ok && metadata.TraceContext != nil {
+		tc := *metadata.TraceContext
+		metadata.TraceContext = &tc
+	}
+
+	return metadata, ok
+}
+
+// requestMetadata resolves metadata of a call from payload and ctx, payload taking precedence,
+// and converts it to protobuf. A new Id is generated if none is provided.
+func requestMetadata(ctx context.Context, payload *Metadata) *protobuf.Metadata {
+	var resolved Metadata
+	if payload != nil {
+		resolved = *payload
+	}
+
+	if fromCtx, ok := MetadataFromContext(ctx); ok {
+		if resolved.Id == "" {
+			resolved.Id = fromCtx.Id
+		}
+
+		switch {
+		case resolved.TraceContext == nil:
+			resolved.TraceContext = fromCtx.TraceContext
+		case resolved.TraceContext.CorrelationId == "" && fromCtx.TraceContext != nil:
+			tc := *resolved.TraceContext
+			tc.CorrelationId = fromCtx.TraceContext.CorrelationId
+			resolved.TraceContext = &tc
+		}
+	}
+
+	if resolved.Id == "" {
+		resolved.Id = uuid.New().String()
+	}
+
+	m := &protobuf.Metadata{Id: resolved.Id}
+	if tc := resolved.TraceContext; tc != nil {
+		m.TraceContext = &protobuf.TraceContext{
+			TraceId:       tc.TraceId,
+			SpanId:        tc.SpanId,
+			TraceFlags:    tc.TraceFlags,
+			TraceState:    tc.TraceState,
+			CorrelationId: tc.CorrelationId,
+		}
+	}
+
+	return m
+}
@@ -0,0 +1,204 @@
+package cryptobrokerclientgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func Test_requestMetadata(t *testing.T) {
+	edge := WithMetadata(context.Background(), Metadata{
+		TraceContext: &TraceContext{TraceId: "edge-trace", CorrelationId: "edge-correlation"},
+	})
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		payload *Metadata
+		want    *protobuf.Metadata
+	}{
+		{
+			name:    "payload metadata is converted",
+			ctx:     context.Background(),
+			payload: &Metadata{Id: "123", TraceContext: &TraceContext{TraceId: "trace", SpanId: "span", TraceFlags: "01", TraceState: "k=v", CorrelationId: "c"}},
+			want:    &protobuf.Metadata{Id: "123", TraceContext: &protobuf.TraceContext{TraceId: "trace", SpanId: "span", TraceFlags: "01", TraceState: "k=v", CorrelationId: "c"}},
+		},
+		{
+			name: "context metadata is used without payload metadata",
+			ctx:  edge,
+			want: &protobuf.Metadata{TraceContext: &protobuf.TraceContext{TraceId: "edge-trace", CorrelationId: "edge-correlation"}},
+		},
+		{
+			name:    "payload trace context takes precedence, missing correlation id is taken from context",
+			ctx:     edge,
+			payload: &Metadata{Id: "123", TraceContext: &TraceContext{TraceId: "trace"}},
+			want:    &protobuf.Metadata{Id: "123", TraceContext: &protobuf.TraceContext{TraceId: "trace", CorrelationId: "edge-correlation"}},
+		},
+		{
+			name:    "payload correlation id takes precedence",
+			ctx:     WithCorrelationID(edge, "other"),
+			payload: &Metadata{TraceContext: &TraceContext{CorrelationId: "payload"}},
+			want:    &protobuf.Metadata{TraceContext: &protobuf.TraceContext{CorrelationId: "payload"}},
+		},
+		{
+			name: "correlation id keeps other context metadata",
+			ctx:  WithCorrelationID(edge, "other"),
+			want: &protobuf.Metadata{TraceContext: &protobuf.TraceContext{TraceId: "edge-trace", CorrelationId: "other"}},
+		},
+		{
+			name: "id is taken from context",
+			ctx:  WithMetadata(context.Background(), Metadata{Id: "edge-id"}),
+			want: &protobuf.Metadata{Id: "edge-id"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := requestMetadata(tt.ctx, tt.payload)
+
+			if tt.want.Id != "" && got.GetId() != tt.want.Id {
+				t.Errorf("requestMetadata() id = %q, want %q", got.GetId(), tt.want.Id)
+			}
+			if got.GetId() == "" {
+				t.Errorf("requestMetadata() id is empty, want generated one")
+			}
+
+			gotTC, wantTC := got.GetTraceContext(), tt.want.GetTraceContext()
+			if gotTC.GetTraceId() != wantTC.GetTraceId() || gotTC.GetSpanId() != wantTC.GetSpanId() ||
+				gotTC.GetTraceFlags() != wantTC.GetTraceFlags() || gotTC.GetTraceState() != wantTC.GetTraceState() ||
+				gotTC.GetCorrelationId() != wantTC.GetCorrelationId() {
+				t.Errorf("requestMetadata() trace context = %v, want %v", gotTC, wantTC)
+			}
+		})
+	}
+}
+
+func Test_requestMetadata_GeneratesIdPerCall(t *testing.T) {
+	ctx := WithCorrelationID(context.Background(), "edge")
+
+	first, second := requestMetadata(ctx, nil), requestMetadata(ctx, nil)
+	if first.GetId() == second.GetId() {
+		t.Errorf("requestMetadata() generated the same id %q for two calls", first.GetId())
+	}
+}
+
+func TestLibrary_HashData_ContextMetadata(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	var got *protobuf.Metadata
+	hashData := broker.hashData
+	broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+		got = req.GetMetadata()
+		return hashData(ctx, req)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx, WithEndpoint(broker.socket))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	if _, err := lib.HashData(WithCorrelationID(ctx, "edge-request"), HashDataPayload{Profile: "Default", Input: []byte("Hello world")}); err != nil {
+		t.Fatalf("Library.HashData() error = %v", err)
+	}
+
+	if got.GetId() == "" || got.GetTraceContext().GetCorrelationId() != "edge-request" {
+		t.Errorf("server got metadata %v, want generated id and correlation id %q", got, "edge-request")
+	}
+}
+
+func TestLibrary_HashData_MetadataEcho(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	var requestID []string
+	hashData := broker.hashData
+	broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		requestID = md.Get(RequestIDHeader)
+
+		resp, err := hashData(ctx, req)
+		resp.Metadata = &protobuf.Metadata{Id: "other"}
+		return resp, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx, WithEndpoint(broker.socket), WithMetadataConfig(MetadataConfig{VerifyEcho: true}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	_, err = lib.HashData(ctx, HashDataPayload{Profile: "Default", Input: []byte("Hello world"), Metadata: &Metadata{Id: "123"}})
+
+	var mismatch *MetadataMismatchError
+	if !errors.Is(err, ErrMetadataMismatch) || !errors.As(err, &mismatch) || mismatch.ResponseID != "other" {
+		t.Errorf("Library.HashData() error = %v, want metadata mismatch", err)
+	}
+	if len(requestID) != 1 || requestID[0] != "123" {
+		t.Errorf("server got %s header %v, want [123]", RequestIDHeader, requestID)
+	}
+}
+
+func TestLibrary_SignCertificate_ContextMetadata(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	var mu sync.Mutex
+	keys := make(map[string]bool)
+	broker.signCertificate = func(ctx context.Context, req *protobuf.SignCertificateRequest) (*protobuf.SignCertificateResponse, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if ids := md.Get(RequestIDHeader); len(ids) != 1 || ids[0] != "edge-request" || req.GetMetadata().GetId() != "edge-request" {
+			return nil, status.Errorf(codes.InvalidArgument, "request id %v, metadata %v, want edge-request", ids, req.GetMetadata())
+		}
+
+		mu.Lock()
+		keys[md.Get(IdempotencyKeyHeader)[0]] = true
+		mu.Unlock()
+
+		return &protobuf.SignCertificateResponse{
+			SignedCertificate: &protobuf.SignCertificateResponse_Pem{Pem: "cert-for-" + req.GetCsr()},
+			Metadata:          req.GetMetadata(),
+		}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx, WithEndpoint(broker.socket))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	// calls of one edge request share the Id, but are distinct signing operations
+	ctx = WithMetadata(ctx, Metadata{Id: "edge-request"})
+	for _, csr := range []string{"A", "B", "A"} {
+		resp, err := lib.SignCertificate(ctx, SignCertificatePayload{Profile: "Default", CSR: []byte(csr), OutputFormat: OutputFormatPem})
+		if err != nil {
+			t.Fatalf("Library.SignCertificate() error = %v", err)
+		}
+		if resp.GetPem() != "cert-for-"+csr {
+			t.Errorf("Library.SignCertificate() = %q, want %q", resp.GetPem(), "cert-for-"+csr)
+		}
+	}
+
+	if len(keys) != 3 || keys["edge-request"] {
+		t.Errorf("server got idempotency keys %v, want 3 keys other than the request id", keys)
+	}
+}
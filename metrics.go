@@ -0,0 +1,66 @@
+package cryptobrokerclientgo
+
+import (
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/metrics"
+)
+
+// Metrics receives measurements of the library, see WithMetrics.
+// Implementations must be safe for concurrent use.
+type Metrics = metrics.Metrics
+
+// MetricLabel is a key value pair distinguishing series of a metric.
+type MetricLabel = metrics.Label
+
+// MemoryMetrics keeps all measurements in memory, so that they can be inspected e.g. in tests.
+type MemoryMetrics = metrics.Memory
+
+// Names of metrics recorded by the library.
+const (
+	// MetricCalls counts completed calls, labeled with method, profile and code.
+	MetricCalls = metrics.Calls
+
+	// MetricCallDuration observes duration of calls including all retries in seconds,
+	// labeled with method, profile and code.
+	MetricCallDuration = metrics.CallDuration
+
+	// MetricCallAttempts observes number of attempts made per call, labeled with method and profile.
+	MetricCallAttempts = metrics.CallAttempts
+
+	// MetricCircuitState gauges state of circuit breakers, 0 closed, 1 half-open and 2 open,
+	// labeled with name, method and profile. Profile is empty unless the breaker is kept per profile.
+	MetricCircuitState = metrics.CircuitState
+
+	// MetricRateLimitWait observes time calls waited for the rate limiter in seconds, labeled with method and profile.
+	MetricRateLimitWait = metrics.RateLimitWait
+
+	// MetricHedges counts attempts sent by hedging in addition to the first one, labeled with method and profile.
+	MetricHedges = metrics.Hedges
+
+	// MetricCacheHits counts calls answered from the result cache, labeled with method and profile.
+	MetricCacheHits = metrics.CacheHits
+
+	// MetricCacheMisses counts cacheable calls sent to the server, labeled with method and profile.
+	MetricCacheMisses = metrics.CacheMisses
+)
+
+// Keys of labels attached to metrics. Code is the name of gRPC status code,
+// or CircuitOpen and CircuitHalfOpen for calls rejected by the circuit breaker,
+// MetadataMismatch, Overloaded, RateLimited and IdempotencyKeyReused for calls failed by the other interceptors.
+const (
+	MetricLabelMethod  = metrics.LabelMethod
+	MetricLabelProfile = metrics.LabelProfile
+	MetricLabelCode    = metrics.LabelCode
+	MetricLabelName    = metrics.LabelName
+)
+
+// NewMemoryMetrics returns empty in-memory metrics.
+func NewMemoryMetrics() *MemoryMetrics {
+	return metrics.NewMemory()
+}
+
+// NewOTelMetrics returns metrics recorded with OpenTelemetry instruments of provider.
+func NewOTelMetrics(provider metric.MeterProvider) Metrics {
+	return metrics.NewOTel(provider)
+}
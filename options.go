@@ -0,0 +1,417 @@
+package cryptobrokerclientgo
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/balancer"
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/interceptor"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// Configuration types of the built-in interceptors.
+type (
+	RetryConfig       = interceptor.RetryConfig
+	RetryBudgetConfig = interceptor.RetryBudgetConfig
+	CircuitConfig     = interceptor.CircuitConfig
+	IdempotencyConfig = interceptor.IdempotencyConfig
+	ConcurrencyConfig = interceptor.ConcurrencyConfig
+	RateLimitConfig   = interceptor.RateLimitConfig
+	HedgingConfig     = interceptor.HedgingConfig
+	CoalescingConfig  = interceptor.CoalescingConfig
+	CacheConfig       = interceptor.CacheConfig
+	MetadataConfig    = interceptor.MetadataConfig
+
+	LoadBalancingConfig = balancer.Config
+	EndpointConfig      = balancer.Endpoint
+	TLSConfig           = balancer.TLSConfig
+)
+
+// Supported jitter modes of retry backoff, see RetryConfig.Jitter.
+const (
+	JitterNone  = interceptor.JitterNone
+	JitterFull  = interceptor.JitterFull
+	JitterEqual = interceptor.JitterEqual
+)
+
+// Supported adaptive concurrency limits, see ConcurrencyConfig.Adaptive.
+const (
+	AdaptiveAIMD     = interceptor.AdaptiveAIMD
+	AdaptiveGradient = interceptor.AdaptiveGradient
+)
+
+// Supported rate limit modes, see RateLimitConfig.Mode.
+const (
+	RateLimitWait     = interceptor.RateLimitWait
+	RateLimitFailFast = interceptor.RateLimitFailFast
+)
+
+// Supported load balancing policies, see LoadBalancingConfig.Policy.
+const (
+	PolicyFailover         = balancer.PolicyFailover
+	PolicyWeighted         = balancer.PolicyWeighted
+	PolicyRoundRobin       = balancer.PolicyRoundRobin
+	PolicyLeastOutstanding = balancer.PolicyLeastOutstanding
+)
+
+// Supported networks of endpoints, see EndpointConfig.Network.
+const (
+	NetworkUnix = balancer.NetworkUnix
+	NetworkTCP  = balancer.NetworkTCP
+)
+
+// ErrUnknownConfig is returned by NewLibrary for configuration values of unsupported types.
+var ErrUnknownConfig = errors.New("unknown configuration type")
+
+// Option configures Library created by New.
+type Option func(*settings) error
+
+// settings gathers everything that Options can configure.
+type settings struct {
+	config                    Config
+	unaryInterceptors         []grpc.UnaryClientInterceptor
+	appendedUnaryInterceptors []grpc.UnaryClientInterceptor
+	streamInterceptors        []grpc.StreamClientInterceptor
+	dialOptions               []grpc.DialOption
+	logger                    *slog.Logger
+	metrics                   Metrics
+	callLogging               *slog.Level
+	auditSink                 AuditSink
+	tracerProvider            trace.TracerProvider
+	propagator                propagation.TextMapPropagator
+	onReady                   func(time.Duration)
+	asyncWorkers              int
+}
+
+// WithConfig replaces the whole configuration, e.g. with one returned by LoadConfig.
+// Options applied afterwards modify the replaced configuration.
+func WithConfig(config Config) Option {
+	return func(s *settings) error {
+		s.config = config
+		return nil
+	}
+}
+
+// WithEndpoint sets path to Unix domain socket of the crypto broker server.
+func WithEndpoint(endpoint string) Option {
+	return func(s *settings) error {
+		if endpoint == "" {
+			return errors.New("endpoint must not be empty")
+		}
+
+		s.config.Endpoint = endpoint
+		return nil
+	}
+}
+
+// WithLoadBalancing spreads calls across multiple crypto broker servers instead of the single Endpoint.
+// Only endpoints passing health checks receive calls, so that calls fail over to the remaining ones.
+func WithLoadBalancing(config LoadBalancingConfig) Option {
+	return func(s *settings) error {
+		s.config.LoadBalancing = config
+		return nil
+	}
+}
+
+// WithGrpcConfig sets connectivity settings.
+func WithGrpcConfig(config GrpcConfig) Option {
+	return func(s *settings) error {
+		s.config.Grpc = config
+		return nil
+	}
+}
+
+// WithConnectTimeout sets how long New waits for the connection to become ready.
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(s *settings) error {
+		if timeout <= 0 {
+			return fmt.Errorf("connect timeout must be positive, got %v", timeout)
+		}
+
+		s.config.Grpc.ConnectTimeout = timeout
+		return nil
+	}
+}
+
+// WithPoolSize opens size connections to every endpoint and spreads calls across them.
+// The connections share the interceptors, so retry budget and circuit breaker state are common to all of them.
+func WithPoolSize(size int) Option {
+	return func(s *settings) error {
+		if size <= 0 {
+			return fmt.Errorf("pool size must be positive, got %d", size)
+		}
+
+		s.config.Grpc.PoolSize = size
+		return nil
+	}
+}
+
+// WithLazyConnect makes New return immediately without waiting for the broker,
+// e.g. when it runs as a sidecar that may start later. The connection is established
+// on first use, so calls fail with codes.Unavailable until the broker is reachable,
+// unless they wait for it, see GrpcConfig.WaitForReady and Library.WaitForReady.
+func WithLazyConnect() Option {
+	return func(s *settings) error {
+		s.config.Grpc.Lazy = true
+		return nil
+	}
+}
+
+// WithOnReady sets callback reporting time elapsed since New was called when the broker
+// became reachable for the first time. It is called before New returns, or from another
+// goroutine in lazy mode, and never if the library is closed before that.
+func WithOnReady(onReady func(elapsed time.Duration)) Option {
+	return func(s *settings) error {
+		if onReady == nil {
+			return errors.New("on ready callback must not be nil")
+		}
+
+		s.onReady = onReady
+		return nil
+	}
+}
+
+// WithAsyncWorkers sets how many operations submitted with HashDataAsync and SignCertificateAsync
+// run at once, 8 by default. Further operations wait in order of submission.
+func WithAsyncWorkers(workers int) Option {
+	return func(s *settings) error {
+		if workers <= 0 {
+			return fmt.Errorf("async workers must be positive, got %d", workers)
+		}
+
+		s.asyncWorkers = workers
+		return nil
+	}
+}
+
+// WithRetry sets configuration of the retry interceptor.
+func WithRetry(config RetryConfig) Option {
+	return func(s *settings) error {
+		s.config.Retry = config
+		return nil
+	}
+}
+
+// WithCircuitBreaker sets configuration of the circuit breaker interceptor.
+func WithCircuitBreaker(config CircuitConfig) Option {
+	return func(s *settings) error {
+		s.config.CircuitBreaker = config
+		return nil
+	}
+}
+
+// WithConcurrency sets limits of calls in flight per method and profile.
+func WithConcurrency(config ConcurrencyConfig) Option {
+	return func(s *settings) error {
+		s.config.Concurrency = config
+		return nil
+	}
+}
+
+// WithRateLimit sets rate limits of calls per method and profile.
+func WithRateLimit(config RateLimitConfig) Option {
+	return func(s *settings) error {
+		s.config.RateLimit = config
+		return nil
+	}
+}
+
+// WithHedging sets methods whose slow calls are sent again, taking the first answer.
+// Only methods which are idempotent according to the retry configuration are hedged.
+func WithHedging(config HedgingConfig) Option {
+	return func(s *settings) error {
+		s.config.Hedging = config
+		return nil
+	}
+}
+
+// WithCache sets result cache of methods, see Library.InvalidateHashCache to drop cached results.
+func WithCache(config CacheConfig) Option {
+	return func(s *settings) error {
+		s.config.Cache = config
+		return nil
+	}
+}
+
+// WithCoalescing sets methods whose identical concurrent calls share a single call.
+func WithCoalescing(config CoalescingConfig) Option {
+	return func(s *settings) error {
+		s.config.Coalescing = config
+		return nil
+	}
+}
+
+// WithIdempotency sets configuration of signing replays.
+func WithIdempotency(config IdempotencyConfig) Option {
+	return func(s *settings) error {
+		s.config.Idempotency = config
+		return nil
+	}
+}
+
+// WithMetadataConfig sets propagation and verification of request metadata.
+func WithMetadataConfig(config MetadataConfig) Option {
+	return func(s *settings) error {
+		s.config.Metadata = config
+		return nil
+	}
+}
+
+// WithUnaryInterceptors prepends unary interceptors to the built-in ones.
+// They run exactly once per call regardless of retries, see New for the complete order.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) Option {
+	return func(s *settings) error {
+		s.unaryInterceptors = append(s.unaryInterceptors, interceptors...)
+		return nil
+	}
+}
+
+// WithAppendedUnaryInterceptors appends unary interceptors to the built-in ones.
+// They run once per attempt right before the request is sent, and are not run
+// at all when the circuit breaker rejects the call. See New for the complete order.
+func WithAppendedUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) Option {
+	return func(s *settings) error {
+		s.appendedUnaryInterceptors = append(s.appendedUnaryInterceptors, interceptors...)
+		return nil
+	}
+}
+
+// WithStreamInterceptors adds stream interceptors, e.g. for health watches.
+// The library has no built-in stream interceptors, so they run in the order given.
+func WithStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) Option {
+	return func(s *settings) error {
+		s.streamInterceptors = append(s.streamInterceptors, interceptors...)
+		return nil
+	}
+}
+
+// WithDialOptions adds options passed to the gRPC client, e.g. max message size, keepalive,
+// compression or user agent. They are applied after the library's own dial options,
+// so they can override them, e.g. replace transport credentials.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(s *settings) error {
+		s.dialOptions = append(s.dialOptions, opts...)
+		return nil
+	}
+}
+
+// WithLogger sets logger used by the library instead of the default slog logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *settings) error {
+		if logger == nil {
+			return errors.New("logger must not be nil")
+		}
+
+		s.logger = logger
+		return nil
+	}
+}
+
+// WithCallLogging enables logging of every call attempt with the logger set by WithLogger.
+// Attempts are logged at level, or at warning level if they failed, with method, profile,
+// Metadata.Id, duration, status code and attempt number. Requests and error messages of the server
+// are never logged, so that key material, CSRs and hashed input can not leak into logs.
+func WithCallLogging(level slog.Level) Option {
+	return func(s *settings) error {
+		s.callLogging = &level
+		return nil
+	}
+}
+
+// WithAuditSink sets sink recording every certificate issued by SignCertificate,
+// e.g. NewFileAuditSink for hash-chained JSON lines.
+func WithAuditSink(sink AuditSink) Option {
+	return func(s *settings) error {
+		if sink == nil {
+			return errors.New("audit sink must not be nil")
+		}
+
+		s.auditSink = sink
+		return nil
+	}
+}
+
+// WithMetrics enables recording of metrics, e.g. with NewOTelMetrics.
+// Latency and results of calls, attempts per call and circuit breaker states are recorded.
+func WithMetrics(m Metrics) Option {
+	return func(s *settings) error {
+		if m == nil {
+			return errors.New("metrics must not be nil")
+		}
+
+		s.metrics = m
+		return nil
+	}
+}
+
+// WithTracerProvider enables OpenTelemetry tracing. A client span is started for every call,
+// its trace context fills Metadata.TraceContext of requests that do not carry one, and it is
+// propagated to the server in gRPC metadata, see WithTextMapPropagator.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(s *settings) error {
+		if provider == nil {
+			return errors.New("tracer provider must not be nil")
+		}
+
+		s.tracerProvider = provider
+		return nil
+	}
+}
+
+// WithTextMapPropagator sets propagator injecting trace context into gRPC metadata
+// when tracing is enabled. Defaults to W3C Trace Context, i.e. the traceparent and tracestate headers.
+func WithTextMapPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(s *settings) error {
+		if propagator == nil {
+			return errors.New("propagator must not be nil")
+		}
+
+		s.propagator = propagator
+		return nil
+	}
+}
+
+// legacyOption translates configuration value accepted by NewLibrary into Option.
+func legacyOption(conf any) (Option, error) {
+	switch t := conf.(type) {
+	case Option:
+		return t, nil
+	case Config:
+		return WithConfig(t), nil
+	case *Config:
+		if t == nil {
+			return nil, fmt.Errorf("%w: nil %T", ErrUnknownConfig, conf)
+		}
+
+		return WithConfig(*t), nil
+	case RetryConfig:
+		return WithRetry(t), nil
+	case CircuitConfig:
+		return WithCircuitBreaker(t), nil
+	case IdempotencyConfig:
+		return WithIdempotency(t), nil
+	case ConcurrencyConfig:
+		return WithConcurrency(t), nil
+	case RateLimitConfig:
+		return WithRateLimit(t), nil
+	case HedgingConfig:
+		return WithHedging(t), nil
+	case CoalescingConfig:
+		return WithCoalescing(t), nil
+	case CacheConfig:
+		return WithCache(t), nil
+	case MetadataConfig:
+		return WithMetadataConfig(t), nil
+	case GrpcConfig:
+		return WithGrpcConfig(t), nil
+	case LoadBalancingConfig:
+		return WithLoadBalancing(t), nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnknownConfig, conf)
+	}
+}
@@ -0,0 +1,696 @@
+package cryptobrokerclientgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewLibrary_UnknownConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		conf any
+	}{
+		{
+			name: "NewLibrary() fails for pointer to interceptor configuration",
+			conf: &RetryConfig{MaxAttempts: 1, InitialBackoff: "1ms"},
+		},
+		{
+			name: "NewLibrary() fails for unrelated struct",
+			conf: struct{ MaxAttempts uint }{MaxAttempts: 1},
+		},
+		{
+			name: "NewLibrary() fails for nil config pointer",
+			conf: (*Config)(nil),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLibrary(context.Background(), tt.conf)
+			if !errors.Is(err, ErrUnknownConfig) {
+				t.Fatalf("NewLibrary() error = %v, want %v", err, ErrUnknownConfig)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidOption(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{name: "New() fails for empty endpoint", opt: WithEndpoint("")},
+		{name: "New() fails for nil logger", opt: WithLogger(nil)},
+		{name: "New() fails for invalid retry configuration", opt: WithRetry(RetryConfig{InitialBackoff: "soon"})},
+		{name: "New() fails for no async workers", opt: WithAsyncWorkers(0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(context.Background(), tt.opt); err == nil {
+				t.Fatal("New() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestNew_Options(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var methods []string
+	record := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		methods = append(methods, method)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	// legacy configuration values and options can be mixed
+	lib, err := NewLibrary(ctx,
+		GrpcConfig{ConnMaxRetries: 10},
+		WithEndpoint(broker.socket),
+		WithUnaryInterceptors(record),
+		WithDialOptions(grpc.WithUserAgent("options-test")),
+		WithLogger(slog.New(slog.DiscardHandler)),
+	)
+	if err != nil {
+		t.Fatalf("NewLibrary() error = %v", err)
+	}
+	defer lib.Close()
+
+	if _, err := lib.HashData(ctx, HashDataPayload{Profile: "Default", Input: []byte("Hello world")}); err != nil {
+		t.Fatalf("Library.HashData() error = %v", err)
+	}
+
+	if len(methods) != 1 || methods[0] != MethodHashData {
+		t.Fatalf("custom interceptor saw %v, want [%s]", methods, MethodHashData)
+	}
+}
+
+func TestNew_InterceptorOrder(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	// first attempt fails, so that the retry interceptor repeats the inner part of the chain
+	var userAgent string
+	attempts := 0
+	hashData := broker.hashData
+	broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		userAgent = strings.Join(md.Get("user-agent"), ",")
+
+		attempts++
+		if attempts == 1 {
+			return nil, status.Error(codes.Unavailable, "temporary failure")
+		}
+
+		return hashData(ctx, req)
+	}
+
+	var calls []string
+	unary := func(name string) grpc.UnaryClientInterceptor {
+		return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			calls = append(calls, name)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+	}
+	stream := func(name string) grpc.StreamClientInterceptor {
+		return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			calls = append(calls, name)
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx,
+		WithEndpoint(broker.socket),
+		WithRetry(RetryConfig{
+			MaxAttempts:          3,
+			InitialBackoff:       "1ms",
+			BackoffMultiplier:    1,
+			RetryableStatusCodes: []codes.Code{codes.Unavailable},
+		}),
+		WithUnaryInterceptors(unary("outer-1"), unary("outer-2")),
+		WithAppendedUnaryInterceptors(unary("inner-1"), unary("inner-2")),
+		WithStreamInterceptors(stream("stream-1"), stream("stream-2")),
+		WithDialOptions(grpc.WithUserAgent("ordering-test")),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	if _, err := lib.HashData(ctx, HashDataPayload{Profile: "Default", Input: []byte("Hello world")}); err != nil {
+		t.Fatalf("Library.HashData() error = %v", err)
+	}
+
+	want := []string{"outer-1", "outer-2", "inner-1", "inner-2", "inner-1", "inner-2"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("unary interceptors ran in order %v, want %v", calls, want)
+	}
+
+	if !strings.HasPrefix(userAgent, "ordering-test") {
+		t.Fatalf("server saw user agent %q, want it to start with %q", userAgent, "ordering-test")
+	}
+
+	calls = nil
+	watch, err := lib.healthClient.Watch(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	_ = watch.CloseSend()
+
+	want = []string{"stream-1", "stream-2"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("stream interceptors ran in order %v, want %v", calls, want)
+	}
+}
+
+func TestNew_Tracing(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	var traceparent string
+	var traceContext *protobuf.TraceContext
+	hashData := broker.hashData
+	broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		traceparent = strings.Join(md.Get("traceparent"), ",")
+		traceContext = req.GetMetadata().GetTraceContext()
+
+		return hashData(ctx, req)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx, WithEndpoint(broker.socket), WithTracerProvider(provider))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	if _, err := lib.HashData(ctx, HashDataPayload{Profile: "Default", Input: []byte("Hello world")}); err != nil {
+		t.Fatalf("Library.HashData() error = %v", err)
+	}
+
+	var span sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		if s.Name() == strings.TrimPrefix(MethodHashData, "/") {
+			span = s
+		}
+	}
+	if span == nil {
+		t.Fatalf("no span recorded for %s", MethodHashData)
+	}
+
+	sc := span.SpanContext()
+	if traceContext.GetTraceId() != sc.TraceID().String() || traceContext.GetSpanId() != sc.SpanID().String() {
+		t.Errorf("server got trace context %v, want trace %s and span %s", traceContext, sc.TraceID(), sc.SpanID())
+	}
+
+	want := "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-" + sc.TraceFlags().String()
+	if traceparent != want {
+		t.Errorf("server got traceparent %q, want %q", traceparent, want)
+	}
+}
+
+func TestNew_Metrics(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	m := NewMemoryMetrics()
+	lib, err := New(ctx, WithEndpoint(broker.socket), WithMetrics(m))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	for range 2 {
+		if _, err := lib.HashData(ctx, HashDataPayload{Profile: "Default", Input: []byte("Hello world")}); err != nil {
+			t.Fatalf("Library.HashData() error = %v", err)
+		}
+	}
+
+	method := MetricLabel{Key: MetricLabelMethod, Value: MethodHashData}
+	profile := MetricLabel{Key: MetricLabelProfile, Value: "Default"}
+
+	if got := m.Counter(MetricCalls, method, profile, MetricLabel{Key: MetricLabelCode, Value: "OK"}); got != 2 {
+		t.Errorf("recorded %v successful calls, want 2", got)
+	}
+	if got := m.Histogram(MetricCallAttempts, method, profile); !reflect.DeepEqual(got, []float64{1, 1}) {
+		t.Errorf("recorded attempts %v, want [1 1]", got)
+	}
+	if state, ok := m.Gauge(MetricCircuitState, method); !ok || state != 0 {
+		t.Errorf("recorded circuit state %v (%v), want closed", state, ok)
+	}
+}
+
+func TestNew_CallLogging(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx, WithEndpoint(broker.socket), WithLogger(logger), WithCallLogging(slog.LevelDebug))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	hash := HashDataPayload{Profile: "Default", Input: []byte("secret-input"), Metadata: &Metadata{Id: "hash-1"}}
+	if _, err := lib.HashData(ctx, hash); err != nil {
+		t.Fatalf("Library.HashData() error = %v", err)
+	}
+
+	// signing is not implemented by the test broker, the attempt is logged anyway
+	sign := SignCertificatePayload{
+		Profile:      "Default",
+		CSR:          []byte("secret-csr"),
+		CAPrivateKey: []byte("secret-private-key"),
+		CACert:       []byte("ca-cert"),
+		Metadata:     &Metadata{Id: "sign-1"},
+	}
+	_, _ = lib.SignCertificate(ctx, sign)
+
+	// payloads logged by the caller are redacted as well
+	logger.Info("payloads", "hash", hash, "sign", sign)
+
+	logs := buf.String()
+	for _, want := range []string{"method=" + MethodHashData, "id=hash-1", "method=" + MethodSignCertificate, "id=sign-1", "code=Unimplemented", "hash.inputSize=12"} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("logs do not contain %q: %s", want, logs)
+		}
+	}
+	for _, secret := range []string{"secret-input", "secret-csr", "secret-private-key"} {
+		if strings.Contains(logs, secret) {
+			t.Errorf("logs contain %q: %s", secret, logs)
+		}
+	}
+}
+
+func TestNew_LoadBalancing(t *testing.T) {
+	first, second := newTestBroker(t), newTestBroker(t)
+	firstCalls, secondCalls := countHashData(first), countHashData(second)
+	for _, broker := range []*testBroker{first, second} {
+		broker.start(t)
+		defer broker.stop()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	payload := HashDataPayload{Profile: "Default", Input: []byte("Hello world")}
+	hash := func(lib *Library, calls int) {
+		t.Helper()
+
+		firstCalls.Store(0)
+		secondCalls.Store(0)
+		for range calls {
+			if _, err := lib.HashData(ctx, payload); err != nil {
+				t.Fatalf("Library.HashData() error = %v", err)
+			}
+		}
+	}
+	eventually := func(what string, condition func() bool) {
+		t.Helper()
+
+		for !condition() {
+			if ctx.Err() != nil {
+				t.Fatalf("%s did not happen", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	endpoints := []EndpointConfig{{Endpoint: first.socket}, {Endpoint: second.socket}}
+
+	t.Run("failover follows health of the preferred endpoint", func(t *testing.T) {
+		lib, err := New(ctx, WithLoadBalancing(LoadBalancingConfig{Endpoints: endpoints, Policy: PolicyFailover}))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		defer lib.Close()
+
+		eventually("calls to the preferred endpoint", func() bool {
+			hash(lib, 5)
+			return firstCalls.Load() == 5
+		})
+
+		first.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+		defer first.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+		eventually("failover to the second endpoint", func() bool {
+			hash(lib, 1)
+			return secondCalls.Load() == 1
+		})
+
+		first.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+		eventually("failback to the preferred endpoint", func() bool {
+			hash(lib, 1)
+			return firstCalls.Load() == 1
+		})
+	})
+
+	t.Run("weighted spreads calls in proportion", func(t *testing.T) {
+		weighted := []EndpointConfig{{Endpoint: first.socket, Weight: 3}, {Endpoint: second.socket, Weight: 1}}
+		lib, err := New(ctx, WithLoadBalancing(LoadBalancingConfig{Endpoints: weighted, Policy: PolicyWeighted}))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		defer lib.Close()
+
+		eventually("both endpoints ready", func() bool {
+			hash(lib, 8)
+			return firstCalls.Load() == 6 && secondCalls.Load() == 2
+		})
+	})
+
+	t.Run("round robin continues on the remaining endpoint when one stops", func(t *testing.T) {
+		lib, err := New(ctx, WithLoadBalancing(LoadBalancingConfig{Endpoints: endpoints, Policy: PolicyRoundRobin}))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		defer lib.Close()
+
+		eventually("both endpoints ready", func() bool {
+			hash(lib, 4)
+			return firstCalls.Load() == 2 && secondCalls.Load() == 2
+		})
+
+		first.stop()
+		defer first.start(t)
+
+		// calls racing with the stop are retried
+		hash(lib, 10)
+		if secondCalls.Load() != 10 {
+			t.Errorf("remaining endpoint got %d of 10 calls", secondCalls.Load())
+		}
+	})
+}
+
+func TestNew_LoadBalancing_TLS(t *testing.T) {
+	local, remote := newTestBroker(t), newTestBroker(t)
+	localCalls, remoteCalls := countHashData(local), countHashData(remote)
+
+	local.start(t)
+	defer local.stop()
+	address, caFile := remote.startTLS(t)
+	defer remote.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	payload := HashDataPayload{Profile: "Default", Input: []byte("Hello world")}
+
+	t.Run("local socket fails over to remote TLS endpoint", func(t *testing.T) {
+		lib, err := New(ctx, WithLoadBalancing(LoadBalancingConfig{Endpoints: []EndpointConfig{
+			{Endpoint: local.socket},
+			{Endpoint: address, Network: NetworkTCP, TLS: &TLSConfig{CAFile: caFile}},
+		}}))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		defer lib.Close()
+
+		if _, err := lib.HashData(ctx, payload); err != nil || localCalls.Load() != 1 {
+			t.Fatalf("Library.HashData() error = %v, local endpoint got %d calls, want 1", err, localCalls.Load())
+		}
+
+		local.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+		for remoteCalls.Load() == 0 {
+			if ctx.Err() != nil {
+				t.Fatal("failover to the remote endpoint did not happen")
+			}
+			if _, err := lib.HashData(ctx, payload); err != nil {
+				t.Fatalf("Library.HashData() error = %v", err)
+			}
+		}
+	})
+
+	t.Run("server not matching the expected name is rejected", func(t *testing.T) {
+		connectCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+
+		// the connection never gets ready
+		_, err := New(connectCtx, WithLoadBalancing(LoadBalancingConfig{Endpoints: []EndpointConfig{
+			{Endpoint: address, Network: NetworkTCP, TLS: &TLSConfig{CAFile: caFile, ServerName: "other.test"}},
+		}}))
+		if err == nil {
+			t.Error("New() error = nil for server not matching the expected name")
+		}
+	})
+
+	t.Run("invalid TLS settings fail", func(t *testing.T) {
+		_, err := New(ctx, WithLoadBalancing(LoadBalancingConfig{Endpoints: []EndpointConfig{
+			{Endpoint: address, Network: NetworkTCP, TLS: &TLSConfig{CAFile: local.socket + ".missing"}},
+		}}))
+		if err == nil {
+			t.Error("New() error = nil for missing CA file")
+		}
+	})
+}
+
+// countHashData counts HashData calls served by broker.
+func countHashData(broker *testBroker) *atomic.Int64 {
+	var calls atomic.Int64
+
+	hashData := broker.hashData
+	broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+		calls.Add(1)
+		return hashData(ctx, req)
+	}
+
+	return &calls
+}
+
+func TestNew_PoolSize(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx,
+		WithEndpoint(broker.socket),
+		WithPoolSize(4),
+		WithRetry(RetryConfig{MaxAttempts: 1, InitialBackoff: "1ms"}),
+		WithCircuitBreaker(CircuitConfig{Name: "pool", MaxRequests: 1, Interval: "1m", Timeout: "1m", ConsecutiveFailures: 2, FailureStatusCodes: []codes.Code{codes.Unavailable}}),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	payload := HashDataPayload{Profile: "Default", Input: []byte("Hello world")}
+	for broker.accepted.Load() < 4 {
+		if ctx.Err() != nil {
+			t.Fatalf("broker accepted %d connections, want 4", broker.accepted.Load())
+		}
+		if _, err := lib.HashData(ctx, payload); err != nil {
+			t.Fatalf("Library.HashData() error = %v", err)
+		}
+	}
+
+	// failures on different connections of the pool trip the same circuit breaker
+	broker.hashData = func(context.Context, *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+		return nil, status.Error(codes.Unavailable, "overloaded")
+	}
+	for range 2 {
+		if _, err := lib.HashData(ctx, payload); status.Code(err) != codes.Unavailable {
+			t.Fatalf("Library.HashData() error = %v, want code %v", err, codes.Unavailable)
+		}
+	}
+	if _, err := lib.HashData(ctx, payload); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Library.HashData() error = %v, want %v", err, ErrCircuitOpen)
+	}
+}
+
+func TestNew_Hedging(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	// the first call stalls until it is cancelled, the hedge is answered right away
+	var calls atomic.Int32
+	cancelled := make(chan struct{})
+	hashData := broker.hashData
+	broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+		if calls.Add(1) == 1 {
+			<-ctx.Done()
+			close(cancelled)
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+
+		return hashData(ctx, req)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx,
+		WithEndpoint(broker.socket),
+		WithHedging(HedgingConfig{Methods: []string{MethodHashData}, Delay: "20ms"}),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	resp, err := lib.HashData(ctx, HashDataPayload{Profile: "Default", Input: []byte("Hello world")})
+	if err != nil {
+		t.Fatalf("Library.HashData() error = %v", err)
+	}
+	if resp.GetHashValueHex() == "" || calls.Load() != 2 {
+		t.Errorf("Library.HashData() = %+v after %d calls, want hash of the hedge", resp, calls.Load())
+	}
+
+	select {
+	case <-cancelled:
+	case <-ctx.Done():
+		t.Fatal("stalled call was not cancelled")
+	}
+}
+
+func TestNew_Coalescing(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	var calls atomic.Int32
+	started, release := make(chan struct{}, 10), make(chan struct{})
+	hashData := broker.hashData
+	broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+		calls.Add(1)
+		started <- struct{}{}
+		<-release
+
+		return hashData(ctx, req)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx,
+		WithEndpoint(broker.socket),
+		WithCoalescing(CoalescingConfig{Methods: []string{MethodHashData}}),
+		WithMetadataConfig(MetadataConfig{VerifyEcho: true}),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	var wg sync.WaitGroup
+	hash := func(id string) {
+		wg.Go(func() {
+			payload := HashDataPayload{Profile: "Default", Input: []byte("Hello world"), Metadata: &Metadata{Id: id}}
+			resp, err := lib.HashData(ctx, payload)
+			if err != nil {
+				t.Errorf("Library.HashData() error = %v", err)
+				return
+			}
+			if resp.GetHashValueHex() == "" || resp.GetMetadata().GetId() != id {
+				t.Errorf("Library.HashData() = %v, want hash echoing id %q", resp, id)
+			}
+		})
+	}
+
+	hash("1")
+	<-started
+	for _, id := range []string{"2", "3", "4"} {
+		hash(id)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("broker received %d calls, want 1", calls.Load())
+	}
+}
+
+func TestNew_Cache(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.start(t)
+	defer broker.stop()
+
+	var calls atomic.Int32
+	hashData := broker.hashData
+	broker.hashData = func(ctx context.Context, req *protobuf.HashDataRequest) (*protobuf.HashDataResponse, error) {
+		calls.Add(1)
+		return hashData(ctx, req)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := New(ctx,
+		WithEndpoint(broker.socket),
+		WithCache(CacheConfig{Methods: []string{MethodHashData}, MaxBytes: 1 << 20, TTL: "1m"}),
+		WithMetadataConfig(MetadataConfig{VerifyEcho: true}),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer lib.Close()
+
+	hash := func(id string) {
+		t.Helper()
+
+		payload := HashDataPayload{Profile: "Default", Input: []byte("Hello world"), Metadata: &Metadata{Id: id}}
+		resp, err := lib.HashData(ctx, payload)
+		if err != nil {
+			t.Fatalf("Library.HashData() error = %v", err)
+		}
+		if resp.GetHashValueHex() == "" || resp.GetMetadata().GetId() != id {
+			t.Errorf("Library.HashData() = %v, want hash echoing id %q", resp, id)
+		}
+	}
+
+	hash("1")
+	hash("2")
+	if calls.Load() != 1 {
+		t.Errorf("broker received %d calls, want 1", calls.Load())
+	}
+
+	lib.InvalidateHashCache("Default")
+	hash("3")
+	if calls.Load() != 2 {
+		t.Errorf("broker received %d calls after invalidation, want 2", calls.Load())
+	}
+}
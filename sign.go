@@ -3,10 +3,13 @@ package cryptobrokerclientgo
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
+	"google.golang.org/grpc/metadata"
 
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/interceptor"
 	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
 )
 
@@ -57,26 +60,26 @@ var ErrInvalidSignOutputFormat = fmt.Errorf("invalid sign output format, must be
 // SignCertificate create certificate using crypto broker.
 // As result it returns signed x509 certificate or non-nil error if any.
 // Please familiarize yourself with the encoding options before using this method.
+//
+// Metadata.Id of the payload is sent as idempotency key, so payloads sharing it denote the same signing operation:
+// they are safe to retry and replays within the idempotency window return the first issued certificate.
+// Payloads sharing it with another CSR or settings fail with IdempotencyKeyReusedError. Calls whose Id is
+// set with WithMetadata get a new idempotency key each, as the Id is shared by all calls made with the context.
+//
+// Issued certificates are recorded with the sink set by WithAuditSink, replays are not recorded again. If that fails,
+// the certificate is returned together with error wrapping ErrAuditFailed.
 func (lib *Library) SignCertificate(ctx context.Context, payload SignCertificatePayload) (*protobuf.SignCertificateResponse, error) {
-	// Create the Metadata on the fly if not provided
-	if payload.Metadata == nil {
-		payload.Metadata = &Metadata{
-			Id: uuid.New().String(),
-		}
+	done, err := lib.begin()
+	if err != nil {
+		return nil, err
 	}
+	defer done()
 
-	// Convert client TraceContext to protobuf TraceContext
-	var protoTraceContext *protobuf.TraceContext
-	if payload.Metadata.TraceContext != nil {
-		protoTraceContext = &protobuf.TraceContext{
-			TraceId:       payload.Metadata.TraceContext.TraceId,
-			SpanId:        payload.Metadata.TraceContext.SpanId,
-			TraceFlags:    payload.Metadata.TraceContext.TraceFlags,
-			TraceState:    payload.Metadata.TraceContext.TraceState,
-			CorrelationId: payload.Metadata.TraceContext.CorrelationId,
-		}
-	}
+	return lib.signCertificate(ctx, payload)
+}
 
+// signCertificate is SignCertificate of call already registered in flight.
+func (lib *Library) signCertificate(ctx context.Context, payload SignCertificatePayload) (*protobuf.SignCertificateResponse, error) {
 	req := &protobuf.SignCertificateRequest{
 		Profile:               payload.Profile,
 		Csr:                   string(payload.CSR),
@@ -84,10 +87,7 @@ func (lib *Library) SignCertificate(ctx context.Context, payload SignCertificate
 		CaCert:                string(payload.CACert),
 		Subject:               payload.Subject,
 		CrlDistributionPoints: payload.CrlDistributionPoints,
-		Metadata: &protobuf.Metadata{
-			Id:           payload.Metadata.Id,
-			TraceContext: protoTraceContext,
-		},
+		Metadata:              requestMetadata(ctx, payload.Metadata),
 	}
 
 	switch payload.OutputFormat {
@@ -115,11 +115,30 @@ func (lib *Library) SignCertificate(ctx context.Context, payload SignCertificate
 		req.ValidNotAfter = toPointerUint64(payload.ValidNotAfter.UTC().Unix())
 	}
 
-	resp, err := lib.client.SignCertificate(ctx, req)
+	// Metadata.Id is kept across retries, so it identifies the signing operation on the server.
+	// An Id set with WithMetadata is shared by all calls made with the context, e.g. of one HTTP request,
+	// so such calls get an idempotency key of their own.
+	key := req.Metadata.Id
+	if fromCtx, _ := MetadataFromContext(ctx); fromCtx.Id != "" && (payload.Metadata == nil || payload.Metadata.Id == "") {
+		key = uuid.New().String()
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, IdempotencyKeyHeader, key)
+
+	var replayed bool
+	resp, err := lib.client.SignCertificate(ctx, req, interceptor.Replayed(&replayed))
 	if err != nil {
 		return nil, err
 	}
 
+	// replayed certificate was audited when it was issued
+	if replayed {
+		return resp, nil
+	}
+
+	if err := lib.audit(ctx, req, resp); err != nil {
+		return resp, err
+	}
+
 	return resp, nil
 }
 
@@ -127,3 +146,30 @@ func toPointerUint64(value int64) *uint64 {
 	v := uint64(value)
 	return &v
 }
+
+// LogValue implements slog.LogValuer, so that the CA private key and the CSR never leak into logs.
+// Only their sizes are logged.
+func (p SignCertificatePayload) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("profile", p.Profile),
+		slog.Int("csrSize", len(p.CSR)),
+		slog.Int("caPrivateKeySize", len(p.CAPrivateKey)),
+		slog.Int("caCertSize", len(p.CACert)),
+		slog.Any("outputFormat", p.OutputFormat),
+		slog.Any("metadata", p.Metadata),
+	}
+	if p.Subject != nil {
+		attrs = append(attrs, slog.String("subject", *p.Subject))
+	}
+	if p.ValidNotBefore != nil {
+		attrs = append(attrs, slog.Time("validNotBefore", *p.ValidNotBefore))
+	}
+	if p.ValidNotAfter != nil {
+		attrs = append(attrs, slog.Time("validNotAfter", *p.ValidNotAfter))
+	}
+	if p.CrlDistributionPoints != nil {
+		attrs = append(attrs, slog.Any("crlDistributionPoints", p.CrlDistributionPoints))
+	}
+
+	return slog.GroupValue(attrs...)
+}
@@ -3,13 +3,20 @@ package cryptobrokerclientgo
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/open-crypto-broker/crypto-broker-client-go/internal/interceptor"
 	"github.com/open-crypto-broker/crypto-broker-client-go/internal/protobuf"
 	"github.com/stretchr/testify/mock"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 func TestLibrary_SignCertificate(t *testing.T) {
@@ -168,3 +175,147 @@ func TestLibrary_SignCertificate(t *testing.T) {
 		})
 	}
 }
+
+func TestLibrary_SignCertificate_Idempotency(t *testing.T) {
+	broker := startTestBroker(t)
+
+	// broker deduplicates signing operations by idempotency key, as the real one does,
+	// and loses the first response on the way back, so that the client has to retry.
+	var (
+		mu     sync.Mutex
+		calls  int
+		issued = make(map[string]string)
+	)
+	broker.signCertificate = func(ctx context.Context, req *protobuf.SignCertificateRequest) (*protobuf.SignCertificateResponse, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		keys := md.Get(IdempotencyKeyHeader)
+		if len(keys) != 1 || keys[0] != req.GetMetadata().GetId() {
+			return nil, status.Errorf(codes.InvalidArgument, "idempotency key %v does not match metadata id", keys)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		calls++
+		cert, ok := issued[keys[0]]
+		if !ok {
+			cert = fmt.Sprintf("certificate #%d", len(issued)+1)
+			issued[keys[0]] = cert
+		}
+
+		if calls == 1 {
+			return nil, status.Error(codes.Unavailable, "connection reset")
+		}
+
+		return &protobuf.SignCertificateResponse{
+			SignedCertificate: &protobuf.SignCertificateResponse_Pem{Pem: cert},
+			Metadata:          req.GetMetadata(),
+		}, nil
+	}
+
+	notIdempotent := false
+	retryConf := interceptor.RetryConfig{
+		MaxAttempts:          3,
+		InitialBackoff:       "1ms",
+		BackoffMultiplier:    1,
+		RetryableStatusCodes: []codes.Code{codes.Unavailable},
+		Methods: map[string]interceptor.RetryConfig{
+			MethodSignCertificate: {Idempotent: &notIdempotent},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := NewLibrary(ctx, retryConf)
+	if err != nil {
+		t.Fatalf("NewLibrary() error = %v", err)
+	}
+	defer lib.Close()
+
+	payload := SignCertificatePayload{
+		Profile:      "Default",
+		OutputFormat: OutputFormatPem,
+		Metadata:     &Metadata{Id: "sign-1"},
+	}
+
+	first, err := lib.SignCertificate(ctx, payload)
+	if err != nil {
+		t.Fatalf("Library.SignCertificate() error = %v", err)
+	}
+
+	replay, err := lib.SignCertificate(ctx, payload)
+	if err != nil {
+		t.Fatalf("Library.SignCertificate() replay error = %v", err)
+	}
+
+	if first.GetPem() != "certificate #1" || replay.GetPem() != first.GetPem() {
+		t.Fatalf("Library.SignCertificate() = %q, replay = %q, want both %q", first.GetPem(), replay.GetPem(), "certificate #1")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(issued) != 1 {
+		t.Fatalf("broker issued %d certificates, want 1", len(issued))
+	}
+
+	// retried attempt reached the broker, replay was answered by the client
+	if calls != 2 {
+		t.Fatalf("broker received %d calls, want 2", calls)
+	}
+}
+
+func TestLibrary_SignCertificate_IdempotencyKeyReused(t *testing.T) {
+	broker := startTestBroker(t)
+
+	var calls atomic.Int32
+	broker.signCertificate = func(ctx context.Context, req *protobuf.SignCertificateRequest) (*protobuf.SignCertificateResponse, error) {
+		calls.Add(1)
+
+		return &protobuf.SignCertificateResponse{
+			SignedCertificate: &protobuf.SignCertificateResponse_Pem{Pem: "cert-for-" + req.GetCsr()},
+			Metadata:          req.GetMetadata(),
+		}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lib, err := NewLibrary(ctx)
+	if err != nil {
+		t.Fatalf("NewLibrary() error = %v", err)
+	}
+	defer lib.Close()
+
+	sign := func(csr string) (*protobuf.SignCertificateResponse, error) {
+		return lib.SignCertificate(ctx, SignCertificatePayload{
+			Profile:      "Default",
+			CSR:          []byte(csr),
+			OutputFormat: OutputFormatPem,
+			Metadata:     &Metadata{Id: "sign-1"},
+		})
+	}
+
+	first, err := sign("A")
+	if err != nil {
+		t.Fatalf("Library.SignCertificate() error = %v", err)
+	}
+	if first.GetPem() != "cert-for-A" {
+		t.Fatalf("Library.SignCertificate() = %q, want %q", first.GetPem(), "cert-for-A")
+	}
+
+	// certificate of A is never returned for B
+	second, err := sign("B")
+	var reused *IdempotencyKeyReusedError
+	if !errors.Is(err, ErrIdempotencyKeyReused) || !errors.As(err, &reused) || reused.Key != "sign-1" {
+		t.Fatalf("Library.SignCertificate() error = %v, want %v", err, ErrIdempotencyKeyReused)
+	}
+	if second.GetPem() != "" {
+		t.Errorf("Library.SignCertificate() = %q, want no certificate", second.GetPem())
+	}
+
+	if calls.Load() != 1 {
+		t.Errorf("broker received %d calls, want 1", calls.Load())
+	}
+}